@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// endpoints to run jobs on demand, authenticated with the adminToken as a bearer token:
+//
+//	POST /admin/jobs/market
+//	POST /admin/jobs/rss                  every feed, or ?feed=<feed url> or ?url=<article url>
+//	POST /admin/jobs/forecast?coin=BTC    one coin, runs as predictions so it is refused while those run
+//	POST /admin/jobs/predictions
+//	POST /admin/jobs/correlation
+//	GET  /admin/runs/{id}
+//
+// any job can be run with ?dry_run=true, which collects its posts instead of publishing them.
+// They are included when polling the run
+func adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/jobs/{job}", triggerJobHandler)
+	mux.HandleFunc("GET /admin/runs/{id}", jobRunHandler)
+	return requireAdminToken(mux)
+}
+
+func requireAdminToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.AdminToken == "" {
+			http.Error(w, "admin api is disabled, set adminToken to enable it", http.StatusNotFound)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// manual jobs that post what a scheduled job posts. They run under its name, so the two are tracked and
+// locked together and can't publish the same post at once
+var runNames = map[string]string{"forecast": "predictions"}
+
+// the name a job is tracked, locked and recorded under
+func runName(job string) string {
+	if name, ok := runNames[job]; ok {
+		return name
+	}
+	return job
+}
+
+// the task of a manually triggered job along with a description of its parameters for the run record
+func manualTask(job string, query url.Values) (string, func(context.Context) (int, error), error) {
+	switch job {
+	case "forecast":
+		coin := strings.ToUpper(query.Get("coin"))
+		if !slices.Contains(watchlist, coin) {
+			return "", nil, fmt.Errorf("coin must be one of %s", strings.Join(watchlist, ", "))
+		}
+		return "coin=" + coin, func(ctx context.Context) (int, error) {
+			return countPosted(dailyForecast(ctx, coin))
+		}, nil
+
+	case "rss":
+		if link := query.Get("url"); link != "" {
+			if u, err := url.Parse(link); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return "", nil, errors.New("url must be an http(s) article url")
+			}
+			return "url=" + link, func(ctx context.Context) (int, error) {
+				return countPosted(postURL(ctx, link))
+			}, nil
+		}
+		if feedURL := query.Get("feed"); feedURL != "" {
+			feed := RSSFeed{URL: feedURL}
+			for _, f := range rssFeeds {
+				if f.URL == feedURL {
+					feed = f
+				}
+			}
+			return "feed=" + feedURL, func(ctx context.Context) (int, error) {
+				return checkFeedAndPost(ctx, feed)
+			}, nil
+		}
+	}
+
+	for _, j := range jobs {
+		if j.Name == job {
+			return "", j.Task, nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("unknown job %q", job)
+}
+
+// one post if it was published
+func countPosted(err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// POST /admin/jobs/{job} starts the job in the background and responds with its run
+func triggerJobHandler(w http.ResponseWriter, r *http.Request) {
+	job := r.PathValue("job")
+	params, task, err := manualTask(job, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dry, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	run, err := jobRuns.runManually(runName(job), params, dry, task)
+	if errors.Is(err, ErrJobRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if errors.Is(err, ErrShuttingDown) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "unable to start job", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Job %s triggered manually as run %d %s", job, run.ID, params)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/runs/"+strconv.FormatInt(run.ID, 10))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// GET /admin/runs/{id} reports the progress of a run
+func jobRunHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "run id must be a number", http.StatusBadRequest)
+		return
+	}
+
+	run, err := getJobRun(r.Context(), id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		http.Error(w, "run not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "unable to read run", http.StatusInternalServerError)
+		return
+	}
+
+	run.DryRun = jobRuns.dryRunOutput(id)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAdminAuth(t *testing.T) {
+	defer func(token string) { config.AdminToken = token }(config.AdminToken)
+	handler := adminHandler()
+
+	request := func(token string) int {
+		r := httptest.NewRequest("POST", "/admin/jobs/unknown", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	config.AdminToken = ""
+	if code := request("anything"); code != http.StatusNotFound {
+		t.Errorf("disabled api returned %d", code)
+	}
+
+	config.AdminToken = "0123456789abcdef"
+	if code := request(""); code != http.StatusUnauthorized {
+		t.Errorf("missing token returned %d", code)
+	}
+	if code := request("0123456789abcdeX"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token returned %d", code)
+	}
+	// authorized, but there is no such job
+	if code := request("0123456789abcdef"); code != http.StatusBadRequest {
+		t.Errorf("unknown job returned %d", code)
+	}
+}
+
+func TestManualTask(t *testing.T) {
+	cases := []struct {
+		job, query, params string
+		valid              bool
+	}{
+		{"market", "", "", true},
+		{"forecast", "coin=btc", "coin=BTC", true},
+		{"forecast", "coin=NOPE", "", false},
+		{"forecast", "", "", false},
+		{"rss", "", "", true},
+		{"rss", "feed=https://www.coindesk.com/feed", "feed=https://www.coindesk.com/feed", true},
+		{"rss", "url=https://example.com/article", "url=https://example.com/article", true},
+		{"rss", "url=javascript:alert(1)", "", false},
+		{"nope", "", "", false},
+	}
+
+	for _, c := range cases {
+		query, _ := url.ParseQuery(c.query)
+		params, task, err := manualTask(c.job, query)
+		if (err == nil) != c.valid {
+			t.Errorf("%s?%s: unexpected error %v", c.job, c.query, err)
+			continue
+		}
+		if c.valid && (task == nil || params != c.params) {
+			t.Errorf("%s?%s: params %q", c.job, c.query, params)
+		}
+	}
+}
+
+func TestRunName(t *testing.T) {
+	if got := runName("forecast"); got != "predictions" {
+		t.Errorf("forecast runs as %q, want predictions", got)
+	}
+	if got := runName("rss"); got != "rss" {
+		t.Errorf("rss runs as %q", got)
+	}
+}
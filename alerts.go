@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	AlertChange = "change" // price moved more than Threshold percent over Window
+	AlertHigh   = "high"   // price is above every sample in Window
+	AlertLow    = "low"    // price is below every sample in Window
+)
+
+type AlertRule struct {
+	Kind      string
+	Window    ChangeWindow
+	Threshold float64
+	Cooldown  time.Duration
+	Coins     []string // coins the rule applies to, all of the watchlist when empty
+	Skip      []string // coins left out when the rule applies to the whole watchlist
+}
+
+// a rule that matched for a coin
+type PriceAlert struct {
+	Rule   AlertRule
+	Coin   string
+	Price  float64
+	Change float64
+}
+
+// the 1h rules rely on the market job sampling prices at least every 40 minutes, the default is every 30
+var alertRules = []AlertRule{
+	{Kind: AlertChange, Window: Window1h, Threshold: 5, Cooldown: 6 * time.Hour, Skip: []string{"USDT"}},
+	{Kind: AlertChange, Window: Window24h, Threshold: 10, Cooldown: 24 * time.Hour, Skip: []string{"USDT"}},
+	{Kind: AlertHigh, Window: Window30d, Cooldown: 24 * time.Hour, Skip: []string{"USDT"}},
+	{Kind: AlertLow, Window: Window30d, Cooldown: 24 * time.Hour, Skip: []string{"USDT"}},
+	// a stablecoin moving at all is news
+	{Kind: AlertChange, Window: Window1h, Threshold: 1, Cooldown: 6 * time.Hour, Coins: []string{"USDT"}},
+}
+
+type MarketAlert struct {
+	Summary   string
+	Currency  string
+	Price     float64
+	Charts    []template.HTML
+	Headlines []ChatterItem
+}
+
+// identifies the rule when recording alerts for the cooldown
+func (r AlertRule) ID() string {
+	if r.Kind == AlertChange {
+		return fmt.Sprintf("%s-%s-%g", r.Kind, r.Window.Name, r.Threshold)
+	}
+	return r.Kind + "-" + r.Window.Name
+}
+
+func (r AlertRule) appliesTo(coin string) bool {
+	if len(r.Coins) > 0 {
+		return slices.Contains(r.Coins, coin)
+	}
+	return !slices.Contains(r.Skip, coin)
+}
+
+// evaluate every rule against the stored prices and post an alert for each match outside its cooldown
+func checkPriceAlerts(ctx context.Context, coins []string) {
+	for _, coin := range coins {
+		for _, rule := range alertRules {
+			if !rule.appliesTo(coin) {
+				continue
+			}
+
+			alert, matched, err := evaluateAlertRule(ctx, rule, coin)
+			if err != nil {
+				if !errors.Is(err, ErrInsufficientData) {
+					log.Println(err)
+				}
+				continue
+			}
+			if !matched || alertInCooldown(ctx, rule, coin) {
+				continue
+			}
+
+			log.Println("Price alert", rule.ID(), coin)
+			err = postPriceAlert(ctx, alert)
+			if err != nil {
+				// not saved, so the alert is tried again on the next check instead of starting its cooldown
+				log.Println(err)
+				continue
+			}
+			if dryRunFrom(ctx) == nil {
+				savePriceAlertToPostgres(ctx, alert)
+			}
+		}
+	}
+}
+
+func evaluateAlertRule(ctx context.Context, rule AlertRule, coin string) (PriceAlert, bool, error) {
+	alert := PriceAlert{Rule: rule, Coin: coin}
+
+	switch rule.Kind {
+	case AlertChange:
+		change, err := getPercentChange(ctx, coin, rule.Window)
+		if err != nil {
+			return alert, false, err
+		}
+		latest, err := getValueFromPostgres(ctx, coin)
+		if err != nil {
+			return alert, false, err
+		}
+		alert.Price = latest.value
+		alert.Change = change
+		return alert, math.Abs(change) >= rule.Threshold, nil
+	case AlertHigh, AlertLow:
+		values, err := getCoinValuesTimeRange(ctx, time.Now().Add(-rule.Window.Offset-rule.Window.Tolerance).Unix(), coin)
+		if err != nil {
+			return alert, false, err
+		}
+		matched, err := isNewExtreme(values, rule)
+		if err != nil {
+			return alert, false, err
+		}
+		if len(values) > 0 {
+			alert.Price = values[0].value
+		}
+		return alert, matched, nil
+	}
+
+	return alert, false, fmt.Errorf("unknown alert kind %q", rule.Kind)
+}
+
+// whether the newest value is above (or below) every earlier value in the window.
+// Values are newest first, as returned by getCoinValuesTimeRange
+func isNewExtreme(values []CoinConversion, rule AlertRule) (bool, error) {
+	if len(values) < 2 {
+		return false, ErrInsufficientData
+	}
+
+	// without history covering the whole window, every price would be a new high
+	latest, oldest := values[0], values[len(values)-1]
+	if latest.createdAt.Sub(oldest.createdAt) < rule.Window.Offset-rule.Window.Tolerance {
+		return false, ErrInsufficientData
+	}
+
+	for _, value := range values[1:] {
+		if rule.Kind == AlertHigh && value.value >= latest.value {
+			return false, nil
+		}
+		if rule.Kind == AlertLow && value.value <= latest.value {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func alertInCooldown(ctx context.Context, rule AlertRule, coin string) bool {
+	var last time.Time
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	err := db.QueryRow(ctx, "SELECT created_at FROM price_alerts WHERE coin = $1 AND rule = $2 ORDER BY created_at DESC LIMIT 1", coin, rule.ID()).Scan(&last)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		// better to skip an alert than to repeat one
+		log.Printf("Error querying database: %v", err)
+		return true
+	}
+
+	return time.Since(last) < rule.Cooldown
+}
+
+func savePriceAlertToPostgres(ctx context.Context, alert PriceAlert) {
+	_, err := dbExec(ctx, "INSERT INTO price_alerts (coin, rule, price, change) VALUES ($1, $2, $3, $4)", alert.Coin, alert.Rule.ID(), alert.Price, alert.Change)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// short headline describing the alert
+func (a PriceAlert) Title() string {
+	switch a.Rule.Kind {
+	case AlertHigh:
+		return fmt.Sprintf("%s hits a new %s high", a.Coin, a.Rule.Window.Name)
+	case AlertLow:
+		return fmt.Sprintf("%s falls to a new %s low", a.Coin, a.Rule.Window.Name)
+	}
+
+	direction := "up"
+	if a.Change < 0 {
+		direction = "down"
+	}
+	return fmt.Sprintf("%s %s %.1f%% in %s", a.Coin, direction, math.Abs(a.Change), a.Rule.Window.Name)
+}
+
+func postPriceAlert(ctx context.Context, alert PriceAlert) error {
+	data := MarketAlert{
+		Summary:   "Market alert: " + alert.Title() + ". " + disclaimer,
+		Currency:  alert.Coin,
+		Price:     alert.Price,
+		Headlines: getCoinHeadlines(ctx, alert.Coin, 5, false),
+	}
+
+	featureImage := ""
+	name := strings.ToLower(alert.Coin) + "-alert-" + time.Now().Format("2006-01-02-1504")
+	chart, err := priceStaticChart(ctx, alert.Coin, Range24h, nil)
+	if err == nil {
+		var card template.HTML
+		card, featureImage, err = chartImageCard(ctx, chart, name)
+		if err == nil {
+			data.Charts = append(data.Charts, card)
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		if chart, err := createPriceChart(ctx, alert.Coin, Range24h, nil); err == nil {
+			data.Charts = append(data.Charts, chart)
+		}
+	}
+	if featureImage == "" {
+		featureImage = fetchUnsplashImage(ctx, "cryptocurrency").Urls.Small
+	}
+
+	html, err := templates.Render("alert.html", data)
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	return createPost(ctx, GhostPost{
+		Title:        alert.Title(),
+		HTML:         html,
+		FeatureImage: featureImage,
+		Featured:     false,
+		Status:       "published",
+		Visibility:   "public",
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsNewExtreme(t *testing.T) {
+	now := time.Now()
+	high := AlertRule{Kind: AlertHigh, Window: Window30d}
+	low := AlertRule{Kind: AlertLow, Window: Window30d}
+
+	// newest first
+	values := []CoinConversion{
+		{createdAt: now, value: 120},
+		{createdAt: now.AddDate(0, 0, -10), value: 110},
+		{createdAt: now.AddDate(0, 0, -30), value: 100},
+	}
+
+	if matched, err := isNewExtreme(values, high); err != nil || !matched {
+		t.Errorf("expected a new high, got %v %v", matched, err)
+	}
+	if matched, err := isNewExtreme(values, low); err != nil || matched {
+		t.Errorf("expected no new low, got %v %v", matched, err)
+	}
+
+	// a week of history can't tell a 30 day high
+	if _, err := isNewExtreme(values[:2], high); err != ErrInsufficientData {
+		t.Errorf("expected insufficient data, got %v", err)
+	}
+}
+
+func TestAlertRule(t *testing.T) {
+	rule := AlertRule{Kind: AlertChange, Window: Window1h, Threshold: 5, Skip: []string{"USDT"}}
+	if rule.ID() != "change-1h-5" {
+		t.Errorf("unexpected id %q", rule.ID())
+	}
+	if !rule.appliesTo("BTC") || rule.appliesTo("USDT") {
+		t.Error("rule should apply to BTC only")
+	}
+
+	title := PriceAlert{Rule: rule, Coin: "BTC", Change: -6.3}.Title()
+	if title != "BTC down 6.3% in 1h" {
+		t.Errorf("unexpected title %q", title)
+	}
+}
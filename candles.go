@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+type CandleInterval struct {
+	Name     string
+	Duration time.Duration
+}
+
+var (
+	Interval1h = CandleInterval{Name: "1h", Duration: time.Hour}
+	Interval4h = CandleInterval{Name: "4h", Duration: 4 * time.Hour}
+	Interval1d = CandleInterval{Name: "1d", Duration: 24 * time.Hour}
+)
+
+var candleIntervals = []CandleInterval{Interval1h, Interval4h, Interval1d}
+
+// open, high, low and close of the samples of a coin within one interval
+type Candle struct {
+	Coin     string
+	Interval string
+	OpenTime time.Time
+	Open     float64
+	High     float64
+	Low      float64
+	Close    float64
+	Samples  int
+}
+
+// group price samples into candles. Buckets are aligned to the interval in UTC
+func aggregateCandles(values []CoinConversion, interval CandleInterval) []Candle {
+	sorted := make([]CoinConversion, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].createdAt.Before(sorted[j].createdAt) })
+
+	candles := []Candle{}
+	for _, value := range sorted {
+		openTime := value.createdAt.UTC().Truncate(interval.Duration)
+
+		if len(candles) == 0 || !candles[len(candles)-1].OpenTime.Equal(openTime) {
+			candles = append(candles, Candle{
+				Coin:     value.coin,
+				Interval: interval.Name,
+				OpenTime: openTime,
+				Open:     value.value,
+				High:     value.value,
+				Low:      value.value,
+			})
+		}
+
+		c := &candles[len(candles)-1]
+		c.High = max(c.High, value.value)
+		c.Low = min(c.Low, value.value)
+		c.Close = value.value
+		c.Samples++
+	}
+
+	return candles
+}
+
+// rebuild the candles of a coin from the given time on. Pass the zero time to rebuild everything
+func updateCandles(ctx context.Context, coin string, since time.Time) error {
+	for _, interval := range candleIntervals {
+		// start at a bucket boundary so the first candle isn't built from part of its samples
+		start := since.UTC().Truncate(interval.Duration)
+
+		values, err := getCoinValuesTimeRange(ctx, start.Unix()-1, coin)
+		if err != nil {
+			return err
+		}
+
+		kept := values[:0]
+		for _, value := range values {
+			if !value.createdAt.Before(start) {
+				kept = append(kept, value)
+			}
+		}
+
+		err = saveCandlesToPostgres(ctx, aggregateCandles(kept, interval))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refresh the candles still open, and the ones just closed, for every coin. A coin without any candles,
+// such as one sampled before candles were kept, has them built from all of its history first
+func updateRecentCandles(ctx context.Context, coins []string) {
+	for _, coin := range coins {
+		since := time.Now().Add(-Interval1d.Duration)
+		found, err := hasCandles(ctx, coin)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !found {
+			log.Printf("No candles for %s yet, building them from its price history", coin)
+			since = time.Time{}
+		}
+
+		err = updateCandles(ctx, coin, since)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func hasCandles(ctx context.Context, coin string) (bool, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+
+	var found bool
+	err := db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM candles WHERE coin = $1)", coin).Scan(&found)
+	return found, err
+}
+
+// save candles to a Postgres database, replacing any existing candle for the same coin, interval and time
+func saveCandlesToPostgres(ctx context.Context, candles []Candle) error {
+	for _, c := range candles {
+		_, err := dbExec(ctx,
+			`INSERT INTO candles (coin, interval, open_time, open, high, low, close, samples) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (coin, interval, open_time) DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close, samples = EXCLUDED.samples`,
+			c.Coin, c.Interval, c.OpenTime, c.Open, c.High, c.Low, c.Close, c.Samples)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// get the candles of a coin at the interval from start to now, oldest first
+func getCandles(ctx context.Context, coin string, interval CandleInterval, start time.Time) ([]Candle, error) {
+	candles := []Candle{}
+
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT coin, interval, open_time, open, high, low, close, samples FROM candles WHERE coin = $1 AND interval = $2 AND open_time >= $3 ORDER BY open_time ASC", coin, interval.Name, start)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		return candles, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Candle
+		err = rows.Scan(&c.Coin, &c.Interval, &c.OpenTime, &c.Open, &c.High, &c.Low, &c.Close, &c.Samples)
+		if err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		candles = append(candles, c)
+	}
+
+	return candles, nil
+}
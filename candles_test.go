@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAggregateCandles(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	values := []CoinConversion{
+		{createdAt: start.Add(90 * time.Minute), value: 7, coin: "BTC"},
+		{createdAt: start, value: 10, coin: "BTC"},
+		{createdAt: start.Add(30 * time.Minute), value: 14, coin: "BTC"},
+		{createdAt: start.Add(45 * time.Minute), value: 9, coin: "BTC"},
+		{createdAt: start.Add(60 * time.Minute), value: 8, coin: "BTC"},
+	}
+
+	candles := aggregateCandles(values, Interval1h)
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(candles))
+	}
+
+	want := Candle{Coin: "BTC", Interval: "1h", OpenTime: start, Open: 10, High: 14, Low: 9, Close: 9, Samples: 3}
+	if candles[0] != want {
+		t.Errorf("got %+v, want %+v", candles[0], want)
+	}
+	if candles[1].Open != 8 || candles[1].Close != 7 || candles[1].Samples != 2 {
+		t.Errorf("unexpected second candle %+v", candles[1])
+	}
+
+	if daily := aggregateCandles(values, Interval1d); len(daily) != 1 || daily[0].High != 14 || daily[0].Low != 7 {
+		t.Errorf("unexpected daily candles %+v", daily)
+	}
+}
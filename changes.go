@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// returned when there is no price sample close enough to the requested time
+var ErrInsufficientData = errors.New("insufficient data")
+
+// a period to compare prices over. Samples are accepted up to Tolerance away from either end.
+// Tolerances are at least half the spacing of the samples: every 30 minutes now, every 4 hours in older history
+type ChangeWindow struct {
+	Name      string
+	Offset    time.Duration
+	Tolerance time.Duration
+}
+
+var (
+	Window1h  = ChangeWindow{Name: "1h", Offset: time.Hour, Tolerance: 20 * time.Minute}
+	Window24h = ChangeWindow{Name: "24h", Offset: 24 * time.Hour, Tolerance: 2 * time.Hour}
+	Window7d  = ChangeWindow{Name: "7d", Offset: 7 * 24 * time.Hour, Tolerance: 6 * time.Hour}
+	Window30d = ChangeWindow{Name: "30d", Offset: 30 * 24 * time.Hour, Tolerance: 24 * time.Hour}
+)
+
+// percent change of a price over a window, or the lack of one
+type PercentChange struct {
+	Percent   float64
+	Available bool
+}
+
+func (c PercentChange) String() string {
+	if !c.Available {
+		return "insufficient data"
+	}
+	return fmt.Sprintf("%.2f", c.Percent)
+}
+
+// get the percent change of a coin over the window ending now, from stored samples only
+func getPercentChange(ctx context.Context, coin string, window ChangeWindow) (float64, error) {
+	now := time.Now()
+	values, err := getCoinValuesTimeRange(ctx, now.Add(-window.Offset-window.Tolerance).Unix(), coin)
+	if err != nil {
+		return 0, err
+	}
+
+	return windowChange(values, now, window)
+}
+
+// the percent change between the samples nearest each end of the window ending at now
+func windowChange(values []CoinConversion, now time.Time, window ChangeWindow) (float64, error) {
+	latest, err := nearestSample(values, now, window.Tolerance)
+	if err != nil {
+		return 0, err
+	}
+	earlier, err := nearestSample(values, now.Add(-window.Offset), window.Tolerance)
+	if err != nil {
+		return 0, err
+	}
+
+	return percentChange(earlier, latest)
+}
+
+// the sample nearest to the given time, no further than tolerance away
+func nearestSample(values []CoinConversion, at time.Time, tolerance time.Duration) (CoinConversion, error) {
+	var nearest CoinConversion
+	found := false
+	for _, value := range values {
+		distance := value.createdAt.Sub(at).Abs()
+		if distance <= tolerance && (!found || distance < nearest.createdAt.Sub(at).Abs()) {
+			nearest = value
+			found = true
+		}
+	}
+
+	if !found {
+		return nearest, ErrInsufficientData
+	}
+	return nearest, nil
+}
+
+func percentChange(from CoinConversion, to CoinConversion) (float64, error) {
+	if from.value == 0 || !to.createdAt.After(from.createdAt) {
+		return 0, ErrInsufficientData
+	}
+	return (to.value - from.value) / from.value * 100, nil
+}
+
+// percent change over the window for publishing, logging anything other than missing data
+func publishedChange(ctx context.Context, coin string, window ChangeWindow) PercentChange {
+	change, err := getPercentChange(ctx, coin, window)
+	if err != nil {
+		if !errors.Is(err, ErrInsufficientData) {
+			log.Println(err)
+		}
+		return PercentChange{}
+	}
+	return PercentChange{Percent: change, Available: true}
+}
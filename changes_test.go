@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentChange(t *testing.T) {
+	now := time.Now()
+
+	change, err := percentChange(CoinConversion{createdAt: now.Add(-24 * time.Hour), value: 200}, CoinConversion{createdAt: now, value: 250})
+	if err != nil || change != 25 {
+		t.Errorf("expected 25%%, got %v %v", change, err)
+	}
+
+	_, err = percentChange(CoinConversion{createdAt: now, value: 200}, CoinConversion{createdAt: now, value: 250})
+	if err != ErrInsufficientData {
+		t.Errorf("expected insufficient data for a single sample, got %v", err)
+	}
+
+	if s := (PercentChange{}).String(); s != "insufficient data" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
+
+// samples every 4 hours, as stored before every market run kept one, must still give the longer changes
+func TestWindowChangeFourHourSamples(t *testing.T) {
+	now := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+	last := now.Add(-time.Hour)
+	values := []CoinConversion{}
+	for at := last; at.After(now.Add(-35 * 24 * time.Hour)); at = at.Add(-4 * time.Hour) {
+		// newest first and rising over time, as getCoinValuesTimeRange returns them
+		values = append(values, CoinConversion{createdAt: at, coin: "BTC", value: 1000 - float64(len(values))})
+	}
+
+	for _, window := range []ChangeWindow{Window24h, Window7d, Window30d} {
+		change, err := windowChange(values, now, window)
+		if err != nil {
+			t.Errorf("%s: %v", window.Name, err)
+			continue
+		}
+		if change <= 0 {
+			t.Errorf("%s: expected a rise, got %v", window.Name, change)
+		}
+	}
+
+	// an hour back falls between two samples
+	if _, err := windowChange(values, now, Window1h); err != ErrInsufficientData {
+		t.Errorf("1h: expected insufficient data, got %v", err)
+	}
+}
+
+func TestNearestSample(t *testing.T) {
+	now := time.Now()
+	values := []CoinConversion{{createdAt: now.Add(-3 * time.Hour), value: 1}, {createdAt: now.Add(-time.Hour), value: 2}}
+
+	if v, err := nearestSample(values, now.Add(-90*time.Minute), time.Hour); err != nil || v.value != 2 {
+		t.Errorf("expected the sample an hour ago, got %v %v", v, err)
+	}
+	if _, err := nearestSample(values, now, 30*time.Minute); err != ErrInsufficientData {
+		t.Errorf("expected insufficient data, got %v", err)
+	}
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type ChartPoint struct {
+	At       time.Time
+	Category int // index into the chart's categories, in place of At
+	Value    float64
+}
+
+type StaticSeries struct {
+	Name    string
+	Points  []ChartPoint
+	Color   color.RGBA
+	Dashed  bool
+	Bars    bool
+	Candles []Candle     // drawn as candlesticks in place of the points
+	Lower   []ChartPoint // when set, the series is a shaded band from these up to the points
+}
+
+// a chart rendered on the server, for places that can't run javascript such as newsletters and social previews
+type StaticChart struct {
+	Title      string
+	Width      int
+	Height     int
+	Series     []StaticSeries
+	Categories []string // labels of an x axis of categories rather than time
+}
+
+var (
+	priceColor     = color.RGBA{0x25, 0x63, 0xeb, 0xff}
+	forecastColor  = color.RGBA{0xf5, 0x9e, 0x0b, 0xff}
+	positiveColor  = color.RGBA{0x16, 0xa3, 0x4a, 0xff}
+	negativeColor  = color.RGBA{0xdc, 0x26, 0x26, 0xff}
+	sentimentColor = color.RGBA{0x7c, 0x3a, 0xed, 0xff}
+	gridColor      = color.RGBA{0xe5, 0xe7, 0xeb, 0xff}
+	axisLabelColor = color.RGBA{0x6b, 0x72, 0x80, 0xff}
+	titleColor     = color.RGBA{0x11, 0x18, 0x27, 0xff}
+)
+
+// space around the plot area for the title and axis labels
+const (
+	chartPadTop    = 40
+	chartPadRight  = 20
+	chartPadBottom = 30
+	chartPadLeft   = 80
+)
+
+// builds a chart of the price history of a coin over the range, with any forecasts appended
+func priceStaticChart(ctx context.Context, coin string, r ChartRange, forecasts []ForecastPoint) (StaticChart, error) {
+	values, err := getCoinValuesTimeRange(ctx, time.Now().Add(-r.Duration).Unix(), coin)
+	if err != nil {
+		return StaticChart{}, err
+	}
+	// a single point would draw an empty chart, better left out of the post
+	if len(values) < 2 {
+		return StaticChart{}, fmt.Errorf("%s price chart, last %s: %w", coin, r.Name, ErrInsufficientData)
+	}
+
+	history := StaticSeries{Name: "Price", Color: priceColor}
+	for _, value := range values {
+		history.Points = append(history.Points, ChartPoint{At: value.createdAt, Value: value.value})
+	}
+	sort.Slice(history.Points, func(i, j int) bool { return history.Points[i].At.Before(history.Points[j].At) })
+
+	chart := StaticChart{
+		Title:  coin + " price (USD), last " + r.Name,
+		Width:  1200,
+		Height: 630,
+		Series: []StaticSeries{history},
+	}
+
+	if len(forecasts) > 0 {
+		chart.Series = append(chart.Series, forecastStaticSeries(history.Points[len(history.Points)-1], forecasts)...)
+	}
+
+	return chart, nil
+}
+
+// the forecast as a dashed line from the latest price, over the same band as the interactive chart
+// that widens with the square root of the horizon
+func forecastStaticSeries(last ChartPoint, forecasts []ForecastPoint) []StaticSeries {
+	sort.Slice(forecasts, func(i, j int) bool { return forecasts[i].At.Before(forecasts[j].At) })
+
+	line := StaticSeries{Name: "Forecast", Color: forecastColor, Dashed: true, Points: []ChartPoint{last}}
+	band := StaticSeries{Name: "Forecast range", Color: forecastColor, Points: []ChartPoint{last}, Lower: []ChartPoint{last}}
+	for _, f := range forecasts {
+		spread := forecastSpread(last.At, f)
+		line.Points = append(line.Points, ChartPoint{At: f.At, Value: f.Value})
+		band.Points = append(band.Points, ChartPoint{At: f.At, Value: f.Value + spread})
+		band.Lower = append(band.Lower, ChartPoint{At: f.At, Value: f.Value - spread})
+	}
+
+	// the band goes underneath the line
+	return []StaticSeries{band, line}
+}
+
+// builds a chart of the headline sentiment index of a coin over the range
+func sentimentStaticChart(ctx context.Context, coin string, r ChartRange) (StaticChart, error) {
+	indexes, err := getSentimentIndexRange(ctx, coin, time.Now().Add(-r.Duration))
+	if err != nil {
+		return StaticChart{}, err
+	}
+	if len(indexes) < 2 {
+		return StaticChart{}, fmt.Errorf("%s sentiment chart, last %s: %w", coin, r.Name, ErrInsufficientData)
+	}
+
+	// a flat line at zero keeps the scale centered on neutral
+	neutral := StaticSeries{Name: "Neutral", Color: gridColor}
+	index := StaticSeries{Name: "Sentiment", Color: sentimentColor}
+	for _, i := range indexes {
+		index.Points = append(index.Points, ChartPoint{At: i.CreatedAt, Value: i.Value})
+	}
+	neutral.Points = []ChartPoint{{At: indexes[0].CreatedAt, Value: 0}, {At: indexes[len(indexes)-1].CreatedAt, Value: 0}}
+
+	return StaticChart{
+		Title:  coin + " headline sentiment index, last " + r.Name,
+		Width:  1200,
+		Height: 400,
+		Series: []StaticSeries{neutral, index},
+	}, nil
+}
+
+// builds a candlestick chart of a coin over the range
+func candleStaticChart(ctx context.Context, coin string, interval CandleInterval, r ChartRange) (StaticChart, error) {
+	candles, err := getCandles(ctx, coin, interval, time.Now().Add(-r.Duration))
+	if err != nil {
+		return StaticChart{}, err
+	}
+	if len(candles) < 2 {
+		return StaticChart{}, fmt.Errorf("%s %s candles: %w", coin, interval.Name, ErrInsufficientData)
+	}
+
+	return StaticChart{
+		Title:  coin + " price (USD), " + interval.Name + " candles, last " + r.Name,
+		Width:  1200,
+		Height: 630,
+		Series: []StaticSeries{{Name: "Price", Candles: candles}},
+	}, nil
+}
+
+// time and value range of all points in the chart. Bar charts always include zero
+func (c StaticChart) bounds() (time.Time, time.Time, float64, float64) {
+	var start, end time.Time
+	low, high := math.Inf(1), math.Inf(-1)
+	bars := false
+
+	for _, series := range c.Series {
+		bars = bars || series.Bars
+		for _, p := range series.Points {
+			if start.IsZero() || p.At.Before(start) {
+				start = p.At
+			}
+			if end.IsZero() || p.At.After(end) {
+				end = p.At
+			}
+			low = math.Min(low, p.Value)
+			high = math.Max(high, p.Value)
+		}
+		for _, p := range series.Lower {
+			low = math.Min(low, p.Value)
+		}
+		// half a candle either side, so the first and last aren't cut off at the edges
+		var half time.Duration
+		if len(series.Candles) > 1 {
+			half = series.Candles[1].OpenTime.Sub(series.Candles[0].OpenTime) / 2
+		}
+		for _, candle := range series.Candles {
+			if start.IsZero() || candle.OpenTime.Add(-half).Before(start) {
+				start = candle.OpenTime.Add(-half)
+			}
+			if end.IsZero() || candle.OpenTime.Add(half).After(end) {
+				end = candle.OpenTime.Add(half)
+			}
+			low = math.Min(low, candle.Low)
+			high = math.Max(high, candle.High)
+		}
+	}
+
+	if math.IsInf(low, 0) {
+		low, high = 0, 1
+	}
+	if bars {
+		low, high = math.Min(low, 0), math.Max(high, 0)
+	}
+	if high == low {
+		high, low = high+1, low-1
+	}
+	if !end.After(start) {
+		end = start.Add(time.Hour)
+	}
+
+	// a little headroom so lines don't touch the edges
+	margin := (high - low) * 0.05
+	return start, end, low - margin, high + margin
+}
+
+// maps a point to pixel coordinates inside the plot area
+func (c StaticChart) projector() func(ChartPoint) (float64, float64) {
+	start, end, low, high := c.bounds()
+	plotW := float64(c.Width - chartPadLeft - chartPadRight)
+	plotH := float64(c.Height - chartPadTop - chartPadBottom)
+
+	return func(p ChartPoint) (float64, float64) {
+		x := chartPadLeft + plotW*p.At.Sub(start).Seconds()/end.Sub(start).Seconds()
+		if len(c.Categories) > 0 {
+			x = chartPadLeft + plotW*(float64(p.Category)+0.5)/float64(len(c.Categories))
+		}
+		y := chartPadTop + plotH*(high-p.Value)/(high-low)
+		return x, y
+	}
+}
+
+// width in pixels of a single daily bar, or of a bar per category
+func (c StaticChart) barWidth() float64 {
+	if len(c.Categories) > 0 {
+		return float64(c.Width-chartPadLeft-chartPadRight) / float64(len(c.Categories)) * 0.6
+	}
+	start, end, _, _ := c.bounds()
+	days := math.Max(end.Sub(start).Hours()/24, 1)
+	return math.Max(float64(c.Width-chartPadLeft-chartPadRight)/(days+1)*0.6, 2)
+}
+
+// a candlestick in pixels: the wick from high to low at x, and the body from open to close
+type candleShape struct {
+	X, High, Low, Top, Bottom, Width float64
+	Color                            color.RGBA
+}
+
+func (c StaticChart) candleShapes(candles []Candle) []candleShape {
+	if len(candles) == 0 {
+		return nil
+	}
+	project := c.projector()
+	width := math.Max(float64(c.Width-chartPadLeft-chartPadRight)/float64(len(candles))*0.6, 1)
+
+	shapes := make([]candleShape, 0, len(candles))
+	for _, candle := range candles {
+		x, high := project(ChartPoint{At: candle.OpenTime, Value: candle.High})
+		_, low := project(ChartPoint{At: candle.OpenTime, Value: candle.Low})
+		_, open := project(ChartPoint{At: candle.OpenTime, Value: candle.Open})
+		_, closed := project(ChartPoint{At: candle.OpenTime, Value: candle.Close})
+
+		shape := candleShape{X: x, High: high, Low: low, Top: math.Min(open, closed), Bottom: math.Max(open, closed), Width: width, Color: positiveColor}
+		if candle.Close < candle.Open {
+			shape.Color = negativeColor
+		}
+		// a body at least a pixel high, so unchanged candles still show
+		shape.Bottom = math.Max(shape.Bottom, shape.Top+1)
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
+
+type axisLabel struct {
+	X      float64
+	Text   string
+	Anchor string // as svg's text-anchor: start, middle or end
+}
+
+// labels under the plot area, the first and last dates or every category
+func (c StaticChart) xLabels() []axisLabel {
+	if len(c.Categories) > 0 {
+		project := c.projector()
+		labels := []axisLabel{}
+		for i, category := range c.Categories {
+			x, _ := project(ChartPoint{Category: i})
+			labels = append(labels, axisLabel{X: x, Text: category, Anchor: "middle"})
+		}
+		return labels
+	}
+
+	// candles are labelled under the first and last, which sit half a candle in from the edges
+	for _, series := range c.Series {
+		if len(series.Candles) > 0 {
+			project := c.projector()
+			first, last := series.Candles[0].OpenTime, series.Candles[len(series.Candles)-1].OpenTime
+			x0, _ := project(ChartPoint{At: first})
+			x1, _ := project(ChartPoint{At: last})
+			return []axisLabel{{X: x0, Text: first.Format("Jan 2"), Anchor: "middle"}, {X: x1, Text: last.Format("Jan 2"), Anchor: "middle"}}
+		}
+	}
+
+	start, end, _, _ := c.bounds()
+	return []axisLabel{
+		{X: chartPadLeft, Text: start.Format("Jan 2"), Anchor: "start"},
+		{X: float64(c.Width - chartPadRight), Text: end.Format("Jan 2"), Anchor: "end"},
+	}
+}
+
+// renders the chart as an svg document
+func (c StaticChart) SVG() []byte {
+	start, _, low, high := c.bounds()
+	project := c.projector()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`, c.Width, c.Height, c.Width, c.Height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`)
+	fmt.Fprintf(&b, `<text x="%d" y="25" font-size="18" fill="%s">%s</text>`, chartPadLeft, hexColor(titleColor), template.HTMLEscapeString(c.Title))
+
+	// horizontal grid lines with value labels
+	for i := 0; i <= 4; i++ {
+		value := low + (high-low)*float64(i)/4
+		_, y := project(ChartPoint{At: start, Value: value})
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="%s"/>`, chartPadLeft, y, c.Width-chartPadRight, y, hexColor(gridColor))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-size="12" text-anchor="end" fill="%s">%s</text>`, chartPadLeft-8, y+4, hexColor(axisLabelColor), formatAxisValue(value))
+	}
+
+	for _, label := range c.xLabels() {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="12" text-anchor="%s" fill="%s">%s</text>`, label.X, c.Height-8, label.Anchor, hexColor(axisLabelColor), template.HTMLEscapeString(label.Text))
+	}
+
+	for _, series := range c.Series {
+		for _, candle := range c.candleShapes(series.Candles) {
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`, candle.X, candle.High, candle.X, candle.Low, hexColor(candle.Color))
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, candle.X-candle.Width/2, candle.Top, candle.Width, candle.Bottom-candle.Top, hexColor(candle.Color))
+		}
+
+		if len(series.Lower) > 0 {
+			outline := []string{}
+			for _, p := range series.Points {
+				x, y := project(p)
+				outline = append(outline, fmt.Sprintf("%.1f,%.1f", x, y))
+			}
+			for i := len(series.Lower) - 1; i >= 0; i-- {
+				x, y := project(series.Lower[i])
+				outline = append(outline, fmt.Sprintf("%.1f,%.1f", x, y))
+			}
+			fmt.Fprintf(&b, `<polygon points="%s" fill="%s" fill-opacity="%.1f"/>`, strings.Join(outline, " "), hexColor(series.Color), bandOpacity)
+			continue
+		}
+
+		if series.Bars {
+			width := c.barWidth()
+			for _, p := range series.Points {
+				x, y := project(p)
+				_, zero := project(ChartPoint{At: p.At, Value: 0})
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, x-width/2, math.Min(y, zero), width, math.Abs(zero-y), hexColor(series.Color))
+			}
+			continue
+		}
+
+		if len(series.Points) == 0 {
+			continue
+		}
+		points := make([]string, 0, len(series.Points))
+		for _, p := range series.Points {
+			x, y := project(p)
+			points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+		}
+		dash := ""
+		if series.Dashed {
+			dash = ` stroke-dasharray="8 6"`
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"%s/>`, strings.Join(points, " "), hexColor(series.Color), dash)
+	}
+
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
+
+// renders the chart as a png image, with the same labels as the svg in a bitmap font
+func (c StaticChart) PNG() ([]byte, error) {
+	start, _, low, high := c.bounds()
+	project := c.projector()
+
+	img := image.NewRGBA(image.Rect(0, 0, c.Width, c.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	drawText(img, chartPadLeft, 25, c.Title, titleColor, 2, "start")
+
+	for i := 0; i <= 4; i++ {
+		value := low + (high-low)*float64(i)/4
+		_, y := project(ChartPoint{At: start, Value: value})
+		drawLine(img, chartPadLeft, y, float64(c.Width-chartPadRight), y, 1, gridColor, false)
+		drawText(img, chartPadLeft-8, y+4, formatAxisValue(value), axisLabelColor, 1, "end")
+	}
+
+	for _, label := range c.xLabels() {
+		drawText(img, label.X, float64(c.Height-8), label.Text, axisLabelColor, 1, label.Anchor)
+	}
+
+	for _, series := range c.Series {
+		for _, candle := range c.candleShapes(series.Candles) {
+			drawLine(img, candle.X, candle.High, candle.X, candle.Low, 1, candle.Color, false)
+			body := image.Rect(int(candle.X-candle.Width/2), int(candle.Top), int(math.Ceil(candle.X+candle.Width/2)), int(math.Ceil(candle.Bottom)))
+			draw.Draw(img, body, image.NewUniform(candle.Color), image.Point{}, draw.Src)
+		}
+
+		if len(series.Lower) > 0 {
+			for i := 1; i < len(series.Points) && i < len(series.Lower); i++ {
+				fillBand(img, project, series.Points[i-1], series.Points[i], series.Lower[i-1], series.Lower[i], series.Color)
+			}
+			continue
+		}
+
+		if series.Bars {
+			width := c.barWidth()
+			for _, p := range series.Points {
+				x, y := project(p)
+				_, zero := project(ChartPoint{At: p.At, Value: 0})
+				rect := image.Rect(int(x-width/2), int(math.Min(y, zero)), int(x+width/2), int(math.Max(y, zero)))
+				draw.Draw(img, rect, image.NewUniform(series.Color), image.Point{}, draw.Src)
+			}
+			continue
+		}
+
+		for i := 1; i < len(series.Points); i++ {
+			x0, y0 := project(series.Points[i-1])
+			x1, y1 := project(series.Points[i])
+			drawLine(img, x0, y0, x1, y1, 3, series.Color, series.Dashed)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// opacity of shaded bands, so the lines behind them still show
+const bandOpacity = 0.2
+
+// shades the band between two upper and two lower points, which share their times, column by column
+func fillBand(img *image.RGBA, project func(ChartPoint) (float64, float64), upper0, upper1, lower0, lower1 ChartPoint, c color.RGBA) {
+	x0, top0 := project(upper0)
+	x1, top1 := project(upper1)
+	_, bottom0 := project(lower0)
+	_, bottom1 := project(lower1)
+
+	// columns from x0 up to but not including x1, so neighbouring segments don't shade a column twice
+	for x := int(math.Ceil(x0)); x < int(math.Ceil(x1)); x++ {
+		t := 0.0
+		if x1 > x0 {
+			t = (float64(x) - x0) / (x1 - x0)
+		}
+		top := int(top0 + (top1-top0)*t)
+		bottom := int(bottom0 + (bottom1-bottom0)*t)
+		for y := top; y <= bottom; y++ {
+			if !(image.Point{X: x, Y: y}.In(img.Bounds())) {
+				continue
+			}
+			under := img.RGBAAt(x, y)
+			img.SetRGBA(x, y, color.RGBA{
+				R: blend(under.R, c.R), G: blend(under.G, c.G), B: blend(under.B, c.B), A: 0xff,
+			})
+		}
+	}
+}
+
+func blend(under uint8, over uint8) uint8 {
+	return uint8(float64(under)*(1-bandOpacity) + float64(over)*bandOpacity)
+}
+
+// draws a line of the given thickness by stamping squares along it
+func drawLine(img *image.RGBA, x0, y0, x1, y1 float64, thickness int, c color.RGBA, dashed bool) {
+	length := math.Hypot(x1-x0, y1-y0)
+	steps := int(math.Ceil(length))
+	if steps == 0 {
+		steps = 1
+	}
+
+	for i := 0; i <= steps; i++ {
+		// 8 pixels on, 6 off
+		if dashed && i%14 >= 8 {
+			continue
+		}
+		t := float64(i) / float64(steps)
+		x := int(x0 + (x1-x0)*t)
+		y := int(y0 + (y1-y0)*t)
+		for dx := -thickness / 2; dx <= thickness/2; dx++ {
+			for dy := -thickness / 2; dy <= thickness/2; dy++ {
+				img.SetRGBA(x+dx, y+dy, c)
+			}
+		}
+	}
+}
+
+// draws text with its baseline at y, scaling each pixel of the 7x13 bitmap font up to a square of scale pixels
+func drawText(img *image.RGBA, x, y float64, text string, c color.RGBA, scale int, anchor string) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, text).Ceil()
+	if width == 0 {
+		return
+	}
+
+	mask := image.NewAlpha(image.Rect(0, 0, width, face.Height))
+	d := font.Drawer{Dst: mask, Src: image.Opaque, Face: face, Dot: fixed.P(0, face.Ascent)}
+	d.DrawString(text)
+
+	left := int(x)
+	switch anchor {
+	case "middle":
+		left -= width * scale / 2
+	case "end":
+		left -= width * scale
+	}
+	top := int(y) - face.Ascent*scale
+
+	for py := 0; py < face.Height; py++ {
+		for px := 0; px < width; px++ {
+			if mask.AlphaAt(px, py).A == 0 {
+				continue
+			}
+			square := image.Rect(left+px*scale, top+py*scale, left+(px+1)*scale, top+(py+1)*scale)
+			draw.Draw(img, square, image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// keeps axis labels short for both large and tiny prices
+func formatAxisValue(v float64) string {
+	if math.Abs(v) >= 1000 {
+		return fmt.Sprintf("%.0f", v)
+	}
+	if math.Abs(v) >= 1 {
+		return fmt.Sprintf("%.2f", v)
+	}
+	return fmt.Sprintf("%.6g", v)
+}
+
+// uploads the chart as svg and png to ghost and returns an image card along with the png url, for use as a feature image
+func chartImageCard(ctx context.Context, chart StaticChart, name string) (template.HTML, string, error) {
+	pngData, err := chart.PNG()
+	if err != nil {
+		return "", "", err
+	}
+
+	pngURL, err := uploadGhostImage(ctx, name+".png", pngData, "image/png")
+	if err != nil {
+		return "", "", err
+	}
+
+	// the png shows everywhere including email, and links to the sharper svg
+	href := pngURL
+	svgURL, err := uploadGhostImage(ctx, name+".svg", chart.SVG(), "image/svg+xml")
+	if err != nil {
+		log.Println(err)
+	} else {
+		href = svgURL
+	}
+
+	card := fmt.Sprintf(`<figure class="kg-card kg-image-card"><a href="%s"><img src="%s" class="kg-image" alt="%s" width="%d" height="%d"></a></figure>`,
+		template.HTMLEscapeString(href), template.HTMLEscapeString(pngURL), template.HTMLEscapeString(chart.Title), chart.Width, chart.Height)
+
+	return template.HTML(card), pngURL, nil
+}
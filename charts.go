@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/render"
+	tpls "github.com/go-echarts/go-echarts/v2/templates"
+)
+
+type ChartRange struct {
+	Name     string
+	Duration time.Duration
+}
+
+var (
+	Range24h = ChartRange{Name: "24h", Duration: 24 * time.Hour}
+	Range7d  = ChartRange{Name: "7d", Duration: 7 * 24 * time.Hour}
+	Range30d = ChartRange{Name: "30d", Duration: 30 * 24 * time.Hour}
+)
+
+// price charts in a forecast post, the first of them is also its feature image
+var forecastChartRanges = []ChartRange{Range30d, Range7d, Range24h}
+
+// a forecasted value of a coin at a point in the future
+type ForecastPoint struct {
+	At    time.Time
+	Value float64
+}
+
+// relative width of the forecast band one week out. The band widens with the square root of the horizon
+var forecastBandWidth = 0.05
+
+// only the chart container and script, so several charts can be embedded in one post
+var chartSnippetTemplate = `{{- define "snippet" }}{{- range .JSAssets.Values }}<script src="{{ . }}"></script>{{- end }}{{ template "base" . }}{{ end }}`
+
+// returns html of a line chart of the price history of a coin over the range, with any forecasts appended
+func createPriceChart(ctx context.Context, coin string, r ChartRange, forecasts []ForecastPoint) (template.HTML, error) {
+	values, err := getCoinValuesTimeRange(ctx, time.Now().Add(-r.Duration).Unix(), coin)
+	if err != nil {
+		return "", err
+	}
+	if len(values) < 2 {
+		return "", fmt.Errorf("%s price chart, last %s: %w", coin, r.Name, ErrInsufficientData)
+	}
+
+	// the database returns newest first
+	sort.Slice(values, func(i, j int) bool { return values[i].createdAt.Before(values[j].createdAt) })
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithInitializationOpts(opts.Initialization{Width: "100%", Height: "400px"}),
+		charts.WithTitleOpts(opts.Title{
+			Title:    coin + " price (USD)",
+			Subtitle: "Last " + r.Name,
+		}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: true, Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: true, Bottom: "0"}),
+		charts.WithXAxisOpts(opts.XAxis{Type: "time"}),
+		charts.WithYAxisOpts(opts.YAxis{Type: "value", Scale: true}),
+	)
+
+	history := make([]opts.LineData, 0, len(values))
+	for _, value := range values {
+		history = append(history, timeSeriesPoint(value.createdAt, value.value))
+	}
+	line.AddSeries("Price", history, charts.WithLineChartOpts(opts.LineChart{ShowSymbol: false}))
+
+	if len(values) > 0 && len(forecasts) > 0 {
+		last := values[len(values)-1]
+		addForecastSeries(line, last, forecasts)
+	}
+
+	return renderChartSnippet(line)
+}
+
+// appends the forecast line, starting at the latest known value, and a shaded band around it
+func addForecastSeries(line *charts.Line, last CoinConversion, forecasts []ForecastPoint) {
+	sort.Slice(forecasts, func(i, j int) bool { return forecasts[i].At.Before(forecasts[j].At) })
+
+	forecastLine := []opts.LineData{timeSeriesPoint(last.createdAt, last.value)}
+	lower := []opts.LineData{timeSeriesPoint(last.createdAt, last.value)}
+	band := []opts.LineData{timeSeriesPoint(last.createdAt, 0)}
+
+	for _, f := range forecasts {
+		spread := forecastSpread(last.createdAt, f)
+
+		forecastLine = append(forecastLine, timeSeriesPoint(f.At, f.Value))
+		lower = append(lower, timeSeriesPoint(f.At, f.Value-spread))
+		band = append(band, timeSeriesPoint(f.At, 2*spread))
+	}
+
+	line.AddSeries("Forecast", forecastLine,
+		charts.WithLineStyleOpts(opts.LineStyle{Type: "dashed"}),
+	)
+
+	// the band is drawn by stacking its width on top of an invisible lower bound
+	line.AddSeries("Forecast range", lower,
+		charts.WithLineChartOpts(opts.LineChart{Stack: "band", ShowSymbol: false}),
+		charts.WithLineStyleOpts(opts.LineStyle{Color: "transparent"}),
+	)
+	line.AddSeries("Forecast range", band,
+		charts.WithLineChartOpts(opts.LineChart{Stack: "band", ShowSymbol: false}),
+		charts.WithLineStyleOpts(opts.LineStyle{Color: "transparent"}),
+		charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: 0.2}),
+	)
+}
+
+// half the width of the forecast band at the forecast, measured from the latest known value
+func forecastSpread(from time.Time, f ForecastPoint) float64 {
+	weeks := f.At.Sub(from).Hours() / (24 * 7)
+	return f.Value * forecastBandWidth * math.Sqrt(math.Max(weeks, 0))
+}
+
+func timeSeriesPoint(t time.Time, value float64) opts.LineData {
+	return opts.LineData{Value: []interface{}{t.Format(time.RFC3339), value}}
+}
+
+// renders a chart without the surrounding html document, wrapped in a ghost html card
+func renderChartSnippet(chart interface{ Validate() }) (template.HTML, error) {
+	chart.Validate()
+
+	tpl := render.MustTemplate("snippet", []string{tpls.BaseTpl, chartSnippetTemplate})
+
+	var buf bytes.Buffer
+	err := tpl.ExecuteTemplate(&buf, "snippet", chart)
+	if err != nil {
+		log.Println("Error executing template:", err)
+		return "", err
+	}
+
+	return template.HTML("<!--kg-card-begin: html-->" + buf.String() + "<!--kg-card-end: html-->"), nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+)
+
+func TestRenderChartSnippet(t *testing.T) {
+	now := time.Now()
+	line := charts.NewLine()
+	addForecastSeries(line, CoinConversion{createdAt: now, value: 100, coin: "BTC"}, []ForecastPoint{
+		{At: now.AddDate(0, 0, 7), Value: 110},
+	})
+
+	html, err := renderChartSnippet(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := string(html)
+	if !strings.HasPrefix(s, "<!--kg-card-begin: html-->") || strings.Contains(s, "<html>") {
+		t.Errorf("chart is not an embeddable snippet: %s", s)
+	}
+	if !strings.Contains(s, "echarts.min.js") || !strings.Contains(s, "Forecast") {
+		t.Errorf("chart is missing assets or series: %s", s)
+	}
+}
+
+func TestStaticChart(t *testing.T) {
+	now := time.Now()
+	chart := StaticChart{
+		Title:  "BTC <price>",
+		Width:  600,
+		Height: 300,
+		Series: []StaticSeries{
+			{Name: "Price", Color: priceColor, Points: []ChartPoint{{At: now.Add(-time.Hour), Value: 100}, {At: now, Value: 120}}},
+			{Name: "Positive", Color: positiveColor, Bars: true, Points: []ChartPoint{{At: now, Value: 3}}},
+		},
+	}
+
+	svg := string(chart.SVG())
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "<polyline") || !strings.Contains(svg, "BTC &lt;price&gt;") {
+		t.Errorf("unexpected svg: %s", svg)
+	}
+
+	data, err := chart.PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 600 || img.Bounds().Dy() != 300 {
+		t.Errorf("unexpected png size %v", img.Bounds())
+	}
+
+	// the title is drawn above the plot area
+	title := false
+	for y := 0; y < chartPadTop; y++ {
+		for x := chartPadLeft; x < 300; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r>>8 == uint32(titleColor.R) && g>>8 == uint32(titleColor.G) && b>>8 == uint32(titleColor.B) {
+				title = true
+			}
+		}
+	}
+	if !title {
+		t.Error("expected the title in the png")
+	}
+
+	// an empty chart still renders
+	if _, err := (StaticChart{Width: 100, Height: 100}).PNG(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCandleStaticChart(t *testing.T) {
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	chart := StaticChart{
+		Title:  "BTC candles",
+		Width:  600,
+		Height: 300,
+		Series: []StaticSeries{{Candles: []Candle{
+			{OpenTime: day, Open: 100, High: 130, Low: 90, Close: 120},
+			{OpenTime: day.AddDate(0, 0, 1), Open: 120, High: 125, Low: 80, Close: 85},
+		}}},
+	}
+
+	// the scale reaches the wicks, not just the bodies
+	if _, _, low, high := chart.bounds(); low > 80 || high < 130 {
+		t.Errorf("bounds %v..%v don't include the wicks", low, high)
+	}
+
+	shapes := chart.candleShapes(chart.Series[0].Candles)
+	if len(shapes) != 2 || shapes[0].Color != positiveColor || shapes[1].Color != negativeColor {
+		t.Fatalf("unexpected candles %+v", shapes)
+	}
+	if !(shapes[0].High < shapes[0].Top && shapes[0].Top < shapes[0].Bottom && shapes[0].Bottom < shapes[0].Low) {
+		t.Errorf("wick should extend past the body: %+v", shapes[0])
+	}
+
+	svg := string(chart.SVG())
+	if strings.Count(svg, hexColor(negativeColor)) != 2 {
+		t.Errorf("expected the falling candle's wick and body in red: %s", svg)
+	}
+	if _, err := chart.PNG(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestForecastStaticSeries(t *testing.T) {
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	last := ChartPoint{At: now, Value: 100}
+	series := forecastStaticSeries(last, []ForecastPoint{{At: now.AddDate(0, 0, 28), Value: 120}, {At: now.AddDate(0, 0, 7), Value: 110}})
+
+	band, line := series[0], series[1]
+	if !line.Dashed || len(line.Points) != 3 || line.Points[1].Value != 110 {
+		t.Errorf("unexpected forecast line %+v", line)
+	}
+	// a week out the band is forecastBandWidth either side, four weeks out twice that
+	if band.Points[0] != last || band.Lower[0] != last {
+		t.Errorf("band should start at the latest price: %+v", band)
+	}
+	if got := band.Points[1].Value - band.Lower[1].Value; math.Abs(got-2*110*forecastBandWidth) > 1e-9 {
+		t.Errorf("band one week out is %v wide", got)
+	}
+	if got := band.Points[2].Value - band.Lower[2].Value; math.Abs(got-4*120*forecastBandWidth) > 1e-9 {
+		t.Errorf("band four weeks out is %v wide", got)
+	}
+
+	chart := StaticChart{Width: 600, Height: 300, Series: append([]StaticSeries{{Color: priceColor, Points: []ChartPoint{{At: now.AddDate(0, 0, -7), Value: 90}, last}}}, series...)}
+	if svg := string(chart.SVG()); !strings.Contains(svg, "<polygon") {
+		t.Errorf("expected the band in the svg: %s", svg)
+	}
+	if _, _, low, _ := chart.bounds(); low > band.Lower[2].Value {
+		t.Errorf("bounds should include the bottom of the band, got %v", low)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/url"
+)
+
+// a headline about a coin, for listing in posts
+type ChatterItem struct {
+	Title     string
+	Publisher string
+	URL       string
+	Score     float64
+}
+
+func (c ChatterItem) Positive() bool {
+	return c.Score >= neutralScoreBand
+}
+
+func (c ChatterItem) Negative() bool {
+	return c.Score <= -neutralScoreBand
+}
+
+// get the latest headlines about a coin, leaving out neutral ones when directional is set
+func getCoinHeadlines(ctx context.Context, coin string, limit int, directional bool) []ChatterItem {
+	query := "SELECT source, COALESCE(title, ''), COALESCE(publisher, ''), COALESCE(score, sentiment) FROM sentiments WHERE coin = $1 ORDER BY created_at DESC LIMIT $2"
+	if directional {
+		query = "SELECT source, COALESCE(title, ''), COALESCE(publisher, ''), COALESCE(score, sentiment) FROM sentiments WHERE coin = $1 AND sentiment <> 0 ORDER BY created_at DESC LIMIT $2"
+	}
+
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, query, coin, limit)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		return []ChatterItem{}
+	}
+	defer rows.Close()
+
+	items := []ChatterItem{}
+	for rows.Next() {
+		var item ChatterItem
+		err = rows.Scan(&item.URL, &item.Title, &item.Publisher, &item.Score)
+		if err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		items = append(items, completeChatterItem(item))
+	}
+
+	return items
+}
+
+// rows saved before titles were stored only have a url
+func completeChatterItem(item ChatterItem) ChatterItem {
+	if item.Publisher == "" {
+		if u, err := url.Parse(item.URL); err == nil {
+			item.Publisher = u.Hostname()
+		}
+	}
+	if item.Title == "" {
+		item.Title = item.URL
+	}
+	return item
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatterTemplate(t *testing.T) {
+	html, err := templates.Render("forecast.html", MarketForecast{
+		Currency: "BTC",
+		Chatter: []ChatterItem{
+			{Title: "Bitcoin <rallies>", Publisher: "CoinDesk", URL: "https://www.coindesk.com/a", Score: 0.7},
+			completeChatterItem(ChatterItem{URL: "https://cointelegraph.com/b", Score: -1}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<a href="https://www.coindesk.com/a">Bitcoin &lt;rallies&gt;</a> (CoinDesk)`,
+		`>Positive</strong>`,
+		`<a href="https://cointelegraph.com/b">https://cointelegraph.com/b</a> (cointelegraph.com)`,
+		`>Negative</strong>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("chatter missing %q:\n%s", want, html)
+		}
+	}
+}
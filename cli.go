@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// a subcommand of the binary, which parses its own flags and arguments
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"serve":        {"run the scheduler and http server (the default)", serveCommand},
+	"fetch-prices": {"fetch the watchlist prices, then update candles, alerts and sentiment", fetchPricesCommand},
+	"ingest-feeds": {"[--feed url] paraphrase and post new articles from the rss feeds", ingestFeedsCommand},
+	"forecast":     {"--coin BTC post a forecast of one coin", forecastCommand},
+	"post-url":     {"<url> paraphrase and post a single article", postURLCommand},
+	"migrate":      {"bring the database schema up to date", migrateCommand},
+	"backfill":     {"[--since 720h] [--coin BTC] rebuild candles and the hourly sentiment index", backfillCommand},
+}
+
+// what a run from the command line is recorded as in job_runs
+const TriggerCLI = "cli"
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := cmd.run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: writer <command> [flags]")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-13s %s\n", name, commands[name].usage)
+	}
+}
+
+// loads configuration, connects to the database, brings the schema up to date and loads templates,
+// the services shared by every command
+func setup() error {
+	var err error
+	config, err = loadConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err = pgxpool.New(context.Background(), config.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	err = migrate(context.Background())
+	if err != nil {
+		return fmt.Errorf("unable to migrate database: %w", err)
+	}
+
+	postFormatter = newFormatter(config.PostLocale)
+
+	templates, err = loadTemplates(config.TemplatesDir)
+	if err != nil {
+		return fmt.Errorf("loading templates: %w", err)
+	}
+
+	return nil
+}
+
+// where commands print flag errors and usage
+var flagOutput io.Writer = os.Stderr
+
+// flags of a command, with the arguments that follow them for its usage
+func newFlagSet(name string, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(flagOutput)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: writer %s [flags] %s\n", name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runs a task once, recording it in job_runs like a scheduled run. The first interrupt lets it finish
+// the item in hand, a second one cancels it
+func runOnce(job, params string, dry bool, task func(context.Context) (int, error)) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		<-signals
+		log.Println("Stopping after the current item, interrupt again to cancel")
+		startDraining()
+		<-signals
+		cancelRuns()
+	}()
+
+	// fail fast rather than race a run of the same job by serve, here or on another instance
+	unlock, err := lockJobRun(job, true)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job, err)
+	}
+	defer unlock()
+
+	ctx := runContext
+	var d *DryRun
+	if dry || config.DryRun {
+		d = newDryRun(config.DryRunDir, job)
+		ctx = withDryRun(ctx, d)
+		params = strings.TrimPrefix(params+"&dry_run=true", "&")
+	}
+
+	run, err := beginJobRun(job, TriggerCLI, params)
+	if err != nil {
+		log.Println("Error recording job run:", err)
+	}
+
+	run.Items, err = task(ctx)
+	run.complete(err)
+
+	if d != nil {
+		out := d.Output()
+		log.Printf("Dry run collected %d posts and %d images in %s", len(out.Posts), len(out.Images), out.Dir)
+	}
+	return err
+}
+
+func serveCommand(args []string) error {
+	fs := newFlagSet("serve", "")
+	dry := fs.Bool("dry-run", false, "render posts to dryRunDir instead of publishing them")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	config.DryRun = config.DryRun || *dry
+
+	// posts are still rendered while draining, so the templates are watched until serve returns
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	go templates.watch(watchCtx, 5*time.Second)
+
+	// create a scheduler, which waits for running jobs up to the shutdown timeout when stopped
+	options := append(coordinationOptions(config.JobCoordination), gocron.WithStopTimeout(config.ShutdownTimeout))
+	s, err := gocron.NewScheduler(options...)
+	if err != nil {
+		return err
+	}
+
+	err = scheduleJobs(s, config.Jobs)
+	if err != nil {
+		return err
+	}
+	s.Start()
+	scheduler = s
+
+	// create a web server to keep the program running and to report its health
+	http.HandleFunc("/healthz", healthzHandler)
+	http.HandleFunc("/health", healthzHandler) // for monitors set up before /healthz
+	http.HandleFunc("/readyz", readyzHandler)
+	http.HandleFunc("/mood", moodHandler)
+	http.HandleFunc("/jobs/runs", jobRunsHandler)
+	http.Handle("/admin/", adminHandler())
+
+	server := &http.Server{Addr: ":8080"}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	select {
+	case err = <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for running jobs", config.ShutdownTimeout)
+	drainRuns(s.Shutdown, config.ShutdownTimeout)
+
+	// let a standby take over straight away instead of waiting for this connection to drop
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if elector != nil {
+		err = elector.Resign(shutdownCtx)
+		if err != nil {
+			log.Println("Error resigning leadership:", err)
+		}
+	}
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Shut down")
+	return nil
+}
+
+func fetchPricesCommand(args []string) error {
+	fs := newFlagSet("fetch-prices", "")
+	dry := fs.Bool("dry-run", false, "render alerts to dryRunDir instead of publishing them")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return runOnce("market", "", *dry, getAllCoinValues)
+}
+
+func ingestFeedsCommand(args []string) error {
+	fs := newFlagSet("ingest-feeds", "")
+	feedURL := fs.String("feed", "", "only this feed, instead of every configured feed")
+	dry := fs.Bool("dry-run", false, "render posts to dryRunDir instead of publishing them")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *feedURL == "" {
+		return runOnce("rss", "", *dry, checkFeedsAndPost)
+	}
+
+	feed := RSSFeed{URL: *feedURL}
+	for _, f := range rssFeeds {
+		if f.URL == *feedURL {
+			feed = f
+		}
+	}
+	return runOnce("rss", "feed="+feed.URL, *dry, func(ctx context.Context) (int, error) {
+		return checkFeedAndPost(ctx, feed)
+	})
+}
+
+func forecastCommand(args []string) error {
+	fs := newFlagSet("forecast", "")
+	coin := fs.String("coin", "", "coin to forecast, one of "+strings.Join(watchlist, ", "))
+	dry := fs.Bool("dry-run", false, "render the post to dryRunDir instead of publishing it")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	*coin = strings.ToUpper(*coin)
+	if !slices.Contains(watchlist, *coin) {
+		fs.Usage()
+		return fmt.Errorf("coin must be one of %s", strings.Join(watchlist, ", "))
+	}
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// locked as predictions, so it fails rather than race the weekly post of the same coin
+	return runOnce(runName("forecast"), "coin="+*coin, *dry, func(ctx context.Context) (int, error) {
+		return countPosted(dailyForecast(ctx, *coin))
+	})
+}
+
+func postURLCommand(args []string) error {
+	fs := newFlagSet("post-url", "<url>")
+	dry := fs.Bool("dry-run", false, "render the post to dryRunDir instead of publishing it")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected one article url")
+	}
+	link := fs.Arg(0)
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return runOnce("rss", "url="+link, *dry, func(ctx context.Context) (int, error) {
+		return countPosted(postURL(ctx, link))
+	})
+}
+
+func migrateCommand(args []string) error {
+	err := newFlagSet("migrate", "").Parse(args)
+	if err != nil {
+		return err
+	}
+
+	// setup applies any pending migrations
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	log.Println("Database schema is up to date")
+	return nil
+}
+
+func backfillCommand(args []string) error {
+	fs := newFlagSet("backfill", "")
+	since := fs.Duration("since", 30*24*time.Hour, "how far back to rebuild")
+	coin := fs.String("coin", "", "only this coin, instead of the whole watchlist")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	coins := watchlist
+	if *coin != "" {
+		*coin = strings.ToUpper(*coin)
+		if !slices.Contains(watchlist, *coin) {
+			fs.Usage()
+			return fmt.Errorf("coin must be one of %s", strings.Join(watchlist, ", "))
+		}
+		coins = []string{*coin}
+	}
+
+	err = setup()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	start := time.Now().Add(-*since)
+	return runOnce("backfill", "since="+since.String(), false, func(ctx context.Context) (int, error) {
+		added := 0
+		for _, c := range coins {
+			if isDraining() {
+				return added, ErrShuttingDown
+			}
+			log.Println("Backfilling", c)
+			err := updateCandles(ctx, c, start)
+			if err != nil {
+				return added, fmt.Errorf("candles of %s: %w", c, err)
+			}
+
+			n, err := backfillSentimentIndex(ctx, c, start)
+			added += n
+			if err != nil {
+				return added, err
+			}
+		}
+		return added, nil
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// bad arguments must be caught before connecting to anything
+func TestCommandArguments(t *testing.T) {
+	cases := []struct {
+		name string
+		args []string
+	}{
+		{"forecast", []string{"--coin", "NOPE"}},
+		{"post-url", []string{}},
+		{"ingest-feeds", []string{"--unknown"}},
+		{"backfill", []string{"--since", "a while"}},
+		{"backfill", []string{"--coin", "NOPE"}},
+	}
+
+	for _, c := range cases {
+		cmd := commands[c.name]
+		if cmd.run == nil {
+			t.Fatalf("no %s command", c.name)
+		}
+		if err := runQuietly(cmd, c.args); err == nil {
+			t.Errorf("%s %v: expected an error", c.name, c.args)
+		}
+	}
+}
+
+func runQuietly(cmd command, args []string) error {
+	stderr := flagOutput
+	flagOutput = io.Discard
+	defer func() { flagOutput = stderr }()
+	return cmd.run(args)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// names a coin goes by in headlines, besides its ticker. Matched case-insensitively
+var coinNames = map[string][]string{
+	"BTC":  {"bitcoin"},
+	"ETH":  {"ethereum", "ether"},
+	"LTC":  {"litecoin"},
+	"DOGE": {"dogecoin"},
+	"SHIB": {"shiba inu"},
+	"LINK": {"chainlink"},
+	"XMR":  {"monero"},
+	"SOL":  {"solana"},
+	"USDT": {"tether"},
+	"XTZ":  {"tezos"},
+}
+
+var wordBoundary = `(^|[^\pL\pN])`
+
+// patterns that find a coin in a text, the ticker matched as written and the names in lower case
+type coinPattern struct {
+	ticker *regexp.Regexp
+	names  *regexp.Regexp // nil if the coin has no other names
+}
+
+// compiled once, headlines are matched against every coin
+var coinPatterns = compileCoinPatterns(watchlist)
+
+func compileCoinPatterns(coins []string) map[string]coinPattern {
+	patterns := map[string]coinPattern{}
+	for _, coin := range coins {
+		p := coinPattern{ticker: regexp.MustCompile(wordBoundary + `\$?` + regexp.QuoteMeta(coin) + `($|[^\pL\pN])`)}
+
+		names := []string{}
+		for _, name := range coinNames[coin] {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		if len(names) > 0 {
+			p.names = regexp.MustCompile(wordBoundary + `(` + strings.Join(names, "|") + `)($|[^\pL\pN])`)
+		}
+
+		patterns[coin] = p
+	}
+	return patterns
+}
+
+// find the watchlist coins mentioned in a text. Tickers must be upper case, optionally with a leading $, so "link" or "sol" in prose don't match
+func detectCoinsInText(text string) []string {
+	lower := strings.ToLower(text)
+	found := []string{}
+
+	for _, coin := range watchlist {
+		p := coinPatterns[coin]
+		if p.ticker.MatchString(text) || (p.names != nil && p.names.MatchString(lower)) {
+			found = append(found, coin)
+		}
+	}
+
+	return found
+}
+
+// find the coins an article is about, asking the llm when the headline and text don't name any directly
+func detectCoins(ctx context.Context, title string, text string) []string {
+	coins := detectCoinsInText(title + "\n" + text)
+	if len(coins) > 0 {
+		return coins
+	}
+
+	coins, err := detectCoinsWithLLM(ctx, title)
+	if err != nil {
+		log.Println(err)
+		return []string{}
+	}
+
+	return coins
+}
+
+func detectCoinsWithLLM(ctx context.Context, title string) ([]string, error) {
+	ctx, cancel := llmContext(ctx)
+	defer cancel()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GeminiKey))
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	model := client.GenerativeModel("gemini-pro")
+	resp, err := model.GenerateContent(ctx, genai.Text("Which of these cryptocurrencies does the following headline mention or directly affect: "+strings.Join(watchlist, ", ")+
+		". Reply with only a comma separated list of their tickers, or NONE if it is about none of them: "+title))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCoinList(fmt.Sprint(resp.Candidates[0].Content.Parts[0])), nil
+}
+
+// keep only watchlist tickers from a comma separated llm reply
+func parseCoinList(reply string) []string {
+	coins := []string{}
+	for _, part := range strings.Split(reply, ",") {
+		coin := strings.ToUpper(strings.Trim(strings.TrimSpace(part), "$.*` "))
+		if slices.Contains(watchlist, coin) && !slices.Contains(coins, coin) {
+			coins = append(coins, coin)
+		}
+	}
+	return coins
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectCoinsInText(t *testing.T) {
+	cases := map[string][]string{
+		"Ethereum bridge hacked for $30M":                  {"ETH"},
+		"Bitcoin and $SOL rally as ETF inflows grow":       {"BTC", "SOL"},
+		"Regulators link exchange to sanctioned wallets":   {},
+		"Chainlink oracles go live; LINK jumps 8%":         {"LINK"},
+		"Shiba Inu burn rate spikes, DOGE follows":         {"DOGE", "SHIB"},
+		"Ethernet standards body meets in Geneva":          {},
+		"Tether mints another billion USDT on Tron":        {"USDT"},
+		"Market update: stocks, gold and bonds all higher": {},
+	}
+
+	for text, want := range cases {
+		got := detectCoinsInText(text)
+		slices.Sort(got)
+		if !slices.Equal(got, want) {
+			t.Errorf("%q: got %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestParseCoinList(t *testing.T) {
+	got := parseCoinList(" eth, $BTC , ETH, FOO")
+	if !slices.Equal(got, []string{"ETH", "BTC"}) {
+		t.Errorf("unexpected coins %v", got)
+	}
+	if got := parseCoinList("NONE"); len(got) != 0 {
+		t.Errorf("expected no coins, got %v", got)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+)
+
+// settings of the writer. Environment variables take precedence over .env, which takes precedence over the config file
+type Config struct {
+	DatabaseURL     string        `yaml:"databaseUrl"`
+	GeminiKey       string        `yaml:"geminiKey"`
+	CoinmarketKey   string        `yaml:"coinmarketKey"`
+	AdminKey        string        `yaml:"adminKey"`
+	AdminID         string        `yaml:"adminId"`
+	UnsplashBearer  string        `yaml:"unsplashBearer"`
+	GhostAdminURL   string        `yaml:"ghostAdminUrl"`
+	TemplatesDir    string        `yaml:"templatesDir"`
+	PostLocale      string        `yaml:"postLocale"`
+	Jobs            JobsConfig    `yaml:"jobs"`
+	JobCoordination string        `yaml:"jobCoordination"` // how replicas share jobs: leader, lock or none
+	AdminToken      string        `yaml:"adminToken"`      // bearer token of the admin http api, which is disabled if empty
+	DryRun          bool          `yaml:"dryRun"`          // render every post without publishing it or saving sentiment
+	DryRunDir       string        `yaml:"dryRunDir"`       // where dry runs write their posts
+	Timeouts        Timeouts      `yaml:"timeouts"`        // per call to each dependency
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"` // how long running jobs get to finish on shutdown before they're cancelled
+}
+
+var config = defaultConfig()
+
+// path of the config file when the configFile variable isn't set. It's fine for it not to exist
+var defaultConfigFile = "config.yaml"
+
+func defaultConfig() Config {
+	return Config{
+		GhostAdminURL:   "https://cryptobunker.org/ghost/api/admin/",
+		TemplatesDir:    "templates",
+		PostLocale:      "en-US",
+		Jobs:            defaultJobsConfig(),
+		JobCoordination: CoordinationLeader,
+		DryRunDir:       "dry-run",
+		Timeouts:        defaultTimeouts(),
+		ShutdownTimeout: 2 * time.Minute,
+	}
+}
+
+// environment variable names of each setting
+func (c *Config) envFields() map[string]*string {
+	return map[string]*string{
+		"databaseUrl":     &c.DatabaseURL,
+		"geminiKey":       &c.GeminiKey,
+		"coinmarketKey":   &c.CoinmarketKey,
+		"adminKey":        &c.AdminKey,
+		"adminId":         &c.AdminID,
+		"unsplashBearer":  &c.UnsplashBearer,
+		"ghostAdminUrl":   &c.GhostAdminURL,
+		"templatesDir":    &c.TemplatesDir,
+		"postLocale":      &c.PostLocale,
+		"jobCoordination": &c.JobCoordination,
+		"adminToken":      &c.AdminToken,
+		"dryRunDir":       &c.DryRunDir,
+	}
+}
+
+// environment variable names of each job setting, such as rssSchedule and rssEnabled
+func (c *Config) jobEnvFields() (map[string]*string, map[string]*bool) {
+	strs, bools := map[string]*string{}, map[string]*bool{}
+	for name, job := range c.Jobs.byName() {
+		strs[name+"Schedule"] = &job.Schedule
+		strs[name+"Timezone"] = &job.Timezone
+		bools[name+"Enabled"] = &job.Enabled
+	}
+	return strs, bools
+}
+
+// settings read from the environment as durations
+func (c *Config) durationEnvFields() map[string]*time.Duration {
+	return map[string]*time.Duration{
+		"shutdownTimeout":   &c.ShutdownTimeout,
+		"databaseTimeout":   &c.Timeouts.Database,
+		"ghostTimeout":      &c.Timeouts.Ghost,
+		"coinmarketTimeout": &c.Timeouts.CoinMarket,
+		"unsplashTimeout":   &c.Timeouts.Unsplash,
+		"llmTimeout":        &c.Timeouts.LLM,
+		"webTimeout":        &c.Timeouts.Web,
+	}
+}
+
+// reads the config file, .env and environment, in increasing order of precedence, and validates the result
+func loadConfig() (Config, error) {
+	c := defaultConfig()
+
+	path := os.Getenv("configFile")
+	if path == "" {
+		path = defaultConfigFile
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = yaml.Unmarshal(data, &c)
+		if err != nil {
+			return c, fmt.Errorf("parsing %s: %w", path, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) || os.Getenv("configFile") != "" {
+		return c, err
+	}
+
+	// .env is optional, in docker the variables are usually set on the container. It doesn't override variables already set
+	err = godotenv.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return c, fmt.Errorf("parsing .env: %w", err)
+	}
+
+	strs, bools := c.jobEnvFields()
+	for name, field := range c.envFields() {
+		strs[name] = field
+	}
+	bools["dryRun"] = &c.DryRun
+	for name, field := range strs {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field = value
+		}
+	}
+	for name, field := range bools {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field, err = strconv.ParseBool(value)
+			if err != nil {
+				return c, fmt.Errorf("%s must be true or false, not %q", name, value)
+			}
+		}
+	}
+	for name, field := range c.durationEnvFields() {
+		if value, ok := os.LookupEnv(name); ok && value != "" {
+			*field, err = time.ParseDuration(value)
+			if err != nil {
+				return c, fmt.Errorf("%s must be a duration such as 90s, not %q", name, value)
+			}
+		}
+	}
+
+	err = c.Validate()
+	return c, err
+}
+
+// checks every setting and returns all problems at once
+func (c *Config) Validate() error {
+	var problems []string
+
+	required := []string{"databaseUrl", "geminiKey", "coinmarketKey", "adminKey", "adminId", "ghostAdminUrl"}
+	fields := c.envFields()
+	for _, name := range required {
+		if strings.TrimSpace(*fields[name]) == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+
+	if c.DatabaseURL != "" {
+		if _, err := pgxpool.ParseConfig(c.DatabaseURL); err != nil {
+			problems = append(problems, "databaseUrl is not a valid postgres connection string: "+err.Error())
+		}
+	}
+
+	// ghost admin api keys are "id:secret", with the secret hex encoded
+	if c.AdminKey != "" {
+		if _, err := hex.DecodeString(c.AdminKey); err != nil {
+			problems = append(problems, "adminKey must be the hex secret after the colon of the ghost admin api key")
+		}
+	}
+
+	if c.GhostAdminURL != "" {
+		u, err := url.Parse(c.GhostAdminURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "ghostAdminUrl must be an absolute http(s) url such as https://example.com/ghost/api/admin/")
+		} else if !strings.HasSuffix(c.GhostAdminURL, "/") {
+			c.GhostAdminURL += "/"
+		}
+	}
+
+	if _, err := language.Parse(c.PostLocale); err != nil {
+		problems = append(problems, "postLocale is not a valid locale such as en-US: "+err.Error())
+	}
+
+	if c.AdminToken != "" && len(c.AdminToken) < 16 {
+		problems = append(problems, "adminToken must be at least 16 characters")
+	}
+	if strings.HasPrefix(strings.ToUpper(c.AdminToken), "CHANGEME") {
+		problems = append(problems, "adminToken is still a placeholder, set a secret of your own or leave it empty")
+	}
+
+	switch c.JobCoordination {
+	case CoordinationLeader, CoordinationLock, CoordinationNone:
+	default:
+		problems = append(problems, "jobCoordination must be leader, lock or none")
+	}
+
+	for name, field := range c.durationEnvFields() {
+		if *field <= 0 {
+			problems = append(problems, name+" must be positive")
+		}
+	}
+
+	schedules := c.Jobs.byName()
+	for _, j := range jobs {
+		job := schedules[j.Name]
+		if !job.Enabled {
+			continue
+		}
+		if _, err := job.definition(); err != nil {
+			problems = append(problems, "jobs."+j.Name+": "+err.Error())
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
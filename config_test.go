@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte("databaseUrl: postgres://writer@localhost/writer\ngeminiKey: from-file\ncoinmarketKey: cmc\nadminId: abc\nadminKey: 0a1b2c\nghostAdminUrl: https://example.com/ghost/api/admin\ntimeouts:\n  llm: 90s\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name := range (&Config{}).envFields() {
+		t.Setenv(name, "")
+	}
+	for name := range (&Config{}).durationEnvFields() {
+		t.Setenv(name, "")
+	}
+	t.Setenv("configFile", file)
+	t.Setenv("geminiKey", "from-env")
+	t.Setenv("ghostTimeout", "5s")
+
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GeminiKey != "from-env" || c.CoinmarketKey != "cmc" {
+		t.Errorf("environment should override the file: %+v", c)
+	}
+	if c.GhostAdminURL != "https://example.com/ghost/api/admin/" || c.TemplatesDir != "templates" {
+		t.Errorf("unexpected defaults: %+v", c)
+	}
+	if c.Timeouts.LLM != 90*time.Second || c.Timeouts.Ghost != 5*time.Second || c.Timeouts.Database != defaultTimeouts().Database {
+		t.Errorf("unexpected timeouts: %+v", c.Timeouts)
+	}
+
+	t.Setenv("configFile", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := loadConfig(); err == nil {
+		t.Error("expected an error for an explicitly set config file that doesn't exist")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	c := defaultConfig()
+	c.DatabaseURL = "postgres://writer@localhost/writer"
+	c.GeminiKey = "gemini"
+	c.AdminKey = "not hex"
+	c.AdminID = "abc"
+	c.PostLocale = "??"
+	c.AdminToken = "CHANGEME-AT-LEAST-16-CHARACTERS"
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"coinmarketKey is required", "adminKey must be", "postLocale", "adminToken is still a placeholder"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in %q", want, err)
+		}
+	}
+}
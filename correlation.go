@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// how far ahead of the sentiment the price change is measured. Nothing shorter than 6h,
+// the sentiment index only moves with the headlines every few hours
+var correlationLags = []time.Duration{6 * time.Hour, 24 * time.Hour, 72 * time.Hour}
+
+// period the monthly analysis covers
+var correlationPeriod = 30 * 24 * time.Hour
+
+// fewer paired samples than this are not reported
+var minCorrelationSamples = 24
+
+// correlation between the sentiment index and the price change over the following Lag
+type LagCorrelation struct {
+	Lag         time.Duration
+	Correlation float64
+	Samples     int
+}
+
+type CoinCorrelation struct {
+	Coin string
+	Lags []LagCorrelation
+}
+
+type CorrelationReport struct {
+	Description string
+	Period      string
+	Lags        []string
+	Coins       []CoinCorrelationRow
+	Charts      []template.HTML
+}
+
+type CoinCorrelationRow struct {
+	Coin   string
+	Values []string
+}
+
+// correlate the sentiment index with later price changes, sampled at the close of every hourly candle
+func lagCorrelations(indexes []SentimentIndex, candles []Candle, lags []time.Duration) []LagCorrelation {
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].CreatedAt.Before(indexes[j].CreatedAt) })
+
+	closes := map[time.Time]float64{}
+	for _, c := range candles {
+		closes[c.OpenTime.UTC()] = c.Close
+	}
+
+	results := []LagCorrelation{}
+	for _, lag := range lags {
+		var sentiment, change []float64
+
+		next := 0
+		for _, c := range candles {
+			closeTime := c.OpenTime.Add(time.Hour)
+
+			// latest index at the close, if it isn't stale
+			for next < len(indexes) && !indexes[next].CreatedAt.After(closeTime) {
+				next++
+			}
+			if next == 0 || closeTime.Sub(indexes[next-1].CreatedAt) > 2*time.Hour {
+				continue
+			}
+
+			future, ok := closes[c.OpenTime.UTC().Add(lag)]
+			if !ok || c.Close == 0 {
+				continue
+			}
+
+			sentiment = append(sentiment, indexes[next-1].Value)
+			change = append(change, (future-c.Close)/c.Close*100)
+		}
+
+		results = append(results, LagCorrelation{Lag: lag, Correlation: pearson(sentiment, change), Samples: len(sentiment)})
+	}
+
+	return results
+}
+
+// Pearson correlation coefficient, NaN when either series is constant or too short
+func pearson(x []float64, y []float64) float64 {
+	n := float64(len(x))
+	if len(x) < 2 || len(x) != len(y) {
+		return math.NaN()
+	}
+
+	var sumX, sumY float64
+	for i := range x {
+		sumX += x[i]
+		sumY += y[i]
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var cov, varX, varY float64
+	for i := range x {
+		cov += (x[i] - meanX) * (y[i] - meanY)
+		varX += (x[i] - meanX) * (x[i] - meanX)
+		varY += (y[i] - meanY) * (y[i] - meanY)
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+
+	return cov / math.Sqrt(varX*varY)
+}
+
+func coinCorrelation(ctx context.Context, coin string, start time.Time) (CoinCorrelation, error) {
+	indexes, err := getSentimentIndexRange(ctx, coin, start)
+	if err != nil {
+		return CoinCorrelation{}, err
+	}
+	candles, err := getCandles(ctx, coin, Interval1h, start)
+	if err != nil {
+		return CoinCorrelation{}, err
+	}
+
+	return CoinCorrelation{Coin: coin, Lags: lagCorrelations(indexes, candles, correlationLags)}, nil
+}
+
+func formatLag(lag time.Duration) string {
+	if lag%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", lag/(24*time.Hour))
+	}
+	return fmt.Sprintf("%dh", lag/time.Hour)
+}
+
+// builds a bar chart of the correlation of a coin at each lag, leaving out lags without enough samples
+func correlationStaticChart(result CoinCorrelation) StaticChart {
+	chart := StaticChart{
+		Title:  result.Coin + " sentiment vs later price change, Pearson's r by lag",
+		Width:  1200,
+		Height: 400,
+	}
+
+	bars := StaticSeries{Name: "Correlation", Color: sentimentColor, Bars: true}
+	for i, lag := range result.Lags {
+		chart.Categories = append(chart.Categories, formatLag(lag.Lag))
+		if math.IsNaN(lag.Correlation) || lag.Samples < minCorrelationSamples {
+			continue
+		}
+		bars.Points = append(bars.Points, ChartPoint{Category: i, Value: lag.Correlation})
+	}
+	chart.Series = []StaticSeries{bars}
+
+	return chart
+}
+
+// a time in the calendar month a period mostly covers. The job runs on the 1st, so this is in the month just ended
+func correlationMonth(start time.Time, end time.Time) time.Time {
+	return start.Add(end.Sub(start) / 2)
+}
+
+// analyze the past month and publish the findings as a data post
+func postSentimentCorrelation(ctx context.Context) error {
+	now := time.Now()
+	start := now.Add(-correlationPeriod)
+	month := correlationMonth(start, now).Format("January 2006")
+
+	report := CorrelationReport{
+		Description: "How did headline sentiment line up with the price moves that followed it in " + month + "? " + disclaimer,
+		Period:      postFormatter.Date(start) + " to " + postFormatter.Date(now),
+	}
+	for _, lag := range correlationLags {
+		report.Lags = append(report.Lags, "r ("+formatLag(lag)+")")
+	}
+
+	results := []CoinCorrelation{}
+	for _, coin := range watchlist {
+		result, err := coinCorrelation(ctx, coin, start)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		row := CoinCorrelationRow{Coin: coin}
+		reported := false
+		for _, lag := range result.Lags {
+			if math.IsNaN(lag.Correlation) || lag.Samples < minCorrelationSamples {
+				row.Values = append(row.Values, "-")
+				continue
+			}
+			row.Values = append(row.Values, fmt.Sprintf("%.2f (n=%d)", lag.Correlation, lag.Samples))
+			reported = true
+		}
+		if reported {
+			report.Coins = append(report.Coins, row)
+			results = append(results, result)
+		}
+	}
+
+	if len(report.Coins) == 0 {
+		return fmt.Errorf("sentiment correlation post: %w", ErrInsufficientData)
+	}
+
+	for _, result := range results {
+		name := "correlation-" + strings.ToLower(result.Coin) + "-" + correlationMonth(start, now).Format("2006-01")
+		card, _, err := chartImageCard(ctx, correlationStaticChart(result), name)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		report.Charts = append(report.Charts, card)
+	}
+
+	html, err := templates.Render("roundup.html", report)
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	return createPost(ctx, GhostPost{
+		Title:        "Sentiment vs Price: " + month,
+		HTML:         html,
+		FeatureImage: fetchUnsplashImage(ctx, "data chart").Urls.Small,
+		Featured:     false,
+		Status:       "published",
+		Visibility:   "public",
+	})
+}
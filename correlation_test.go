@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPearson(t *testing.T) {
+	if r := pearson([]float64{1, 2, 3}, []float64{2, 4, 6}); math.Abs(r-1) > 1e-9 {
+		t.Errorf("expected 1, got %v", r)
+	}
+	if r := pearson([]float64{1, 2, 3}, []float64{3, 2, 1}); math.Abs(r+1) > 1e-9 {
+		t.Errorf("expected -1, got %v", r)
+	}
+	if r := pearson([]float64{1, 1, 1}, []float64{1, 2, 3}); !math.IsNaN(r) {
+		t.Errorf("expected NaN for a constant series, got %v", r)
+	}
+}
+
+func TestLagCorrelations(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	// sentiment alternates, and the price follows it an hour later
+	var indexes []SentimentIndex
+	var candles []Candle
+	price := 100.0
+	for i := 0; i < 48; i++ {
+		value := float64(i%3) - 1
+		indexes = append(indexes, SentimentIndex{CreatedAt: start.Add(time.Duration(i)*time.Hour + 50*time.Minute), Value: value})
+		candles = append(candles, Candle{OpenTime: start.Add(time.Duration(i) * time.Hour), Close: price})
+		price *= 1 + value/100
+	}
+
+	results := lagCorrelations(indexes, candles, []time.Duration{time.Hour})
+	if len(results) != 1 || results[0].Samples != 47 || results[0].Correlation < 0.99 {
+		t.Errorf("unexpected correlation %+v", results)
+	}
+
+	if got := formatLag(72 * time.Hour); got != "3d" {
+		t.Errorf("unexpected lag label %q", got)
+	}
+}
+
+func TestCorrelationStaticChart(t *testing.T) {
+	chart := correlationStaticChart(CoinCorrelation{Coin: "BTC", Lags: []LagCorrelation{
+		{Lag: 6 * time.Hour, Correlation: 0.4, Samples: 100},
+		{Lag: 24 * time.Hour, Correlation: -0.2, Samples: 100},
+		{Lag: 72 * time.Hour, Correlation: 0.9, Samples: 3},
+	}})
+
+	if len(chart.Categories) != 3 || chart.Categories[2] != "3d" {
+		t.Errorf("unexpected categories %v", chart.Categories)
+	}
+	if points := chart.Series[0].Points; len(points) != 2 || points[1].Category != 1 {
+		t.Errorf("expected bars for the lags with enough samples, got %+v", points)
+	}
+
+	svg := string(chart.SVG())
+	if !strings.Contains(svg, ">1d<") || strings.Count(svg, "<rect") != 3 {
+		t.Errorf("unexpected svg: %s", svg)
+	}
+}
+
+func TestCorrelationMonth(t *testing.T) {
+	cases := map[time.Time]string{
+		time.Date(2026, 11, 1, 12, 0, 0, 0, time.UTC): "October 2026",
+		time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC):  "February 2026", // 30 days back reaches into January
+		time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC):  "December 2026",
+	}
+	for now, want := range cases {
+		if got := correlationMonth(now.Add(-correlationPeriod), now).Format("January 2006"); got != want {
+			t.Errorf("%s: got %q, want %q", now.Format("2006-01-02"), got, want)
+		}
+	}
+}
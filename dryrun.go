@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// what a dry run would have sent to ghost
+type DryRunOutput struct {
+	Dir    string      `json:"dir,omitempty"`
+	Posts  []GhostPost `json:"posts"`
+	Images []string    `json:"images"`
+}
+
+// collects the posts and images of a run instead of publishing them. While a dry run is in the context,
+// nothing is written to ghost or to the sentiment, alert and rss tables
+type DryRun struct {
+	mu  sync.Mutex
+	out DryRunOutput
+}
+
+type dryRunKey struct{}
+
+// a dry run that keeps its output in memory, and also writes it to a new directory under dir if dir isn't empty
+func newDryRun(dir string, job string) *DryRun {
+	d := &DryRun{out: DryRunOutput{Posts: []GhostPost{}, Images: []string{}}}
+	if dir != "" {
+		d.out.Dir = filepath.Join(dir, time.Now().Format("20060102-150405")+"-"+job)
+	}
+	return d
+}
+
+func withDryRun(ctx context.Context, d *DryRun) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, d)
+}
+
+// the dry run of the context, or nil when publishing for real
+func dryRunFrom(ctx context.Context) *DryRun {
+	d, _ := ctx.Value(dryRunKey{}).(*DryRun)
+	return d
+}
+
+// a copy of everything collected so far
+func (d *DryRun) Output() DryRunOutput {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := d.out
+	out.Posts = append([]GhostPost{}, d.out.Posts...)
+	out.Images = append([]string{}, d.out.Images...)
+	return out
+}
+
+// keeps the post, writing the exact ghost payload and its html for previewing
+func (d *DryRun) recordPost(post GhostPost) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.out.Posts = append(d.out.Posts, post)
+	if d.out.Dir == "" {
+		log.Println("Dry run, not publishing:", post.Title)
+		return nil
+	}
+
+	payload, err := json.MarshalIndent(GhostPosts{Posts: []GhostPost{post}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("post-%d", len(d.out.Posts))
+	err = d.write(name+".json", payload)
+	if err != nil {
+		return err
+	}
+	err = d.write(name+".html", []byte(post.HTML))
+	if err != nil {
+		return err
+	}
+
+	log.Println("Dry run, post written to", filepath.Join(d.out.Dir, name+".json"))
+	return nil
+}
+
+// keeps the image and returns the url to use in its place, which is relative to the html written next to it
+func (d *DryRun) recordImage(name string, data []byte) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.out.Images = append(d.out.Images, name)
+	if d.out.Dir == "" {
+		return "dry-run:" + name, nil
+	}
+
+	return name, d.write(name, data)
+}
+
+func (d *DryRun) write(name string, data []byte) error {
+	err := os.MkdirAll(d.out.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(d.out.Dir, filepath.Base(name)), data, 0644)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	d := newDryRun(t.TempDir(), "forecast")
+	ctx := withDryRun(context.Background(), d)
+
+	chart := StaticChart{Title: "BTC price", Width: 200, Height: 100, Series: []StaticSeries{{
+		Points: []ChartPoint{{At: time.Now().Add(-time.Hour), Value: 1}, {At: time.Now(), Value: 2}},
+	}}}
+	card, featureImage, err := chartImageCard(ctx, chart, "btc-price")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if featureImage != "btc-price.png" || !strings.Contains(string(card), `href="btc-price.svg"`) {
+		t.Errorf("unexpected card %q with feature image %q", card, featureImage)
+	}
+
+	err = createPost(ctx, GhostPost{Title: "Weekly BTC", HTML: string(card), FeatureImage: featureImage})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// must not reach the database, which isn't connected in tests
+	markArticleParaphrased(ctx, "https://example.com/article")
+
+	out := d.Output()
+	if len(out.Posts) != 1 || out.Posts[0].Title != "Weekly BTC" || len(out.Images) != 2 {
+		t.Errorf("unexpected output %+v", out)
+	}
+	for _, name := range []string{"post-1.json", "post-1.html", "btc-price.png", "btc-price.svg"} {
+		if _, err := os.Stat(filepath.Join(out.Dir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if dryRunFrom(context.Background()) != nil {
+		t.Error("expected no dry run outside of one")
+	}
+}
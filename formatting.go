@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// formats numbers, prices, percentages and dates for readers of one locale
+type Formatter struct {
+	printer *message.Printer
+	numbers numberFormat
+	dates   dateFormat
+}
+
+type numberFormat struct {
+	symbolAfter bool // whether the currency symbol follows the amount, after a non-breaking space
+}
+
+type dateFormat struct {
+	layout string // time layout, with "January" standing in for the localized month name
+	months [12]string
+}
+
+var englishMonths = [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"}
+
+// currency formats by base language, or by full tag where regions differ. Separators come from the printer
+var numberFormats = map[string]numberFormat{
+	"en": {},
+	"de": {symbolAfter: true},
+	"fr": {symbolAfter: true},
+	"es": {symbolAfter: true},
+	"it": {symbolAfter: true},
+	"pt": {symbolAfter: true},
+}
+
+// date formats by base language, or by full tag where regions differ
+var dateFormats = map[string]dateFormat{
+	"en":    {layout: "January 2, 2006", months: englishMonths},
+	"en-GB": {layout: "2 January 2006", months: englishMonths},
+	"de":    {layout: "2. January 2006", months: [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"}},
+	"fr":    {layout: "2 January 2006", months: [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"}},
+	"es":    {layout: "2 de January de 2006", months: [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"}},
+	"it":    {layout: "2 January 2006", months: [12]string{"gennaio", "febbraio", "marzo", "aprile", "maggio", "giugno", "luglio", "agosto", "settembre", "ottobre", "novembre", "dicembre"}},
+	"pt":    {layout: "2 de January de 2006", months: [12]string{"janeiro", "fevereiro", "março", "abril", "maio", "junho", "julho", "agosto", "setembro", "outubro", "novembro", "dezembro"}},
+}
+
+// significant digits shown for prices below one dollar, so SHIB doesn't print as $0.00
+var priceSignificantDigits = 4
+
+// formatter for posts, in the configured locale
+var postFormatter = newFormatter("en-US")
+
+// formatter for llm prompts, which stays the same whatever locale the posts are written in
+var promptFormatter = newFormatter("en-US")
+
+// create a formatter for a BCP 47 locale such as "en-US" or "de-DE", falling back to US English
+func newFormatter(locale string) *Formatter {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		tag = language.AmericanEnglish
+	}
+
+	return &Formatter{
+		printer: message.NewPrinter(tag),
+		numbers: localeFormat(numberFormats, tag),
+		dates:   localeFormat(dateFormats, tag),
+	}
+}
+
+// the format for the full tag, else for its base language, else for English
+func localeFormat[T any](formats map[string]T, tag language.Tag) T {
+	if format, ok := formats[tag.String()]; ok {
+		return format
+	}
+	base, _ := tag.Base()
+	if format, ok := formats[base.String()]; ok {
+		return format
+	}
+	return formats["en"]
+}
+
+// a number with locale separators and the given number of decimals
+func (f *Formatter) Number(v float64, decimals int) string {
+	return f.printer.Sprintf("%.*f", decimals, v)
+}
+
+// a number rounded to the given significant digits, for values whose magnitude varies between coins
+func (f *Formatter) Significant(v float64, digits int) string {
+	if v == 0 {
+		return f.Number(0, 0)
+	}
+	return f.Number(v, significantDecimals(v, digits))
+}
+
+// a USD price, with cents for larger prices and significant digits for fractions of a dollar
+func (f *Formatter) Price(v float64) string {
+	decimals := 2
+	if v != 0 && math.Abs(v) < 1 {
+		decimals = max(significantDecimals(v, priceSignificantDigits), 2)
+	}
+	// trailing zeros past the cents carry no information
+	for decimals > 2 && strings.HasSuffix(strconv.FormatFloat(v, 'f', decimals, 64), "0") {
+		decimals--
+	}
+	amount := f.Number(math.Abs(v), decimals)
+
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+	if f.numbers.symbolAfter {
+		return sign + amount + "\u00a0$"
+	}
+	return sign + "$" + amount
+}
+
+// decimals needed to show the given number of significant digits
+func significantDecimals(v float64, digits int) int {
+	return max(digits-1-int(math.Floor(math.Log10(math.Abs(v)))), 0)
+}
+
+// a signed percentage. Accepts a float64 or a PercentChange
+func (f *Formatter) Percent(v interface{}) string {
+	switch p := v.(type) {
+	case PercentChange:
+		if !p.Available {
+			return p.String()
+		}
+		return f.Percent(p.Percent)
+	case float64:
+		sign := "+"
+		if p < 0 {
+			sign = "-"
+		}
+		return sign + f.Number(math.Abs(p), 2) + "%"
+	}
+	return fmt.Sprint(v)
+}
+
+// a date with the month spelled out in the locale's language
+func (f *Formatter) Date(t time.Time) string {
+	layout := strings.Replace(f.dates.layout, "January", "\x00", 1)
+	return strings.Replace(t.Format(layout), "\x00", f.dates.months[t.Month()-1], 1)
+}
+
+// functions available to every template
+func (f *Formatter) FuncMap() map[string]interface{} {
+	return map[string]interface{}{
+		"currency": f.Price,
+		"percent":  f.Percent,
+		"number":   f.Number,
+		"date":     f.Date,
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatting(t *testing.T) {
+	f := newFormatter("en-US")
+	cases := map[float64]string{
+		64231.123456789: "$64,231.12",
+		1234567:         "$1,234,567.00",
+		12.5:            "$12.50",
+		0.00002345678:   "$0.00002346",
+		0.5:             "$0.50",
+		-1500:           "-$1,500.00",
+		0:               "$0.00",
+	}
+	for v, want := range cases {
+		if got := f.Price(v); got != want {
+			t.Errorf("Price(%v) = %q, want %q", v, got, want)
+		}
+	}
+
+	if got := f.Percent(PercentChange{Percent: 1.234, Available: true}); got != "+1.23%" {
+		t.Errorf("unexpected percent %q", got)
+	}
+	if got := f.Percent(PercentChange{}); got != "insufficient data" {
+		t.Errorf("unexpected percent %q", got)
+	}
+	if got := f.Percent(-0.5); got != "-0.50%" {
+		t.Errorf("unexpected percent %q", got)
+	}
+	if got := f.Significant(-0.000123456, 4); got != "-0.0001235" {
+		t.Errorf("unexpected significant %q", got)
+	}
+}
+
+func TestLocaleFormatting(t *testing.T) {
+	day := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		locale, price, percent, date string
+	}{
+		{"en-US", "$64,231.12", "+1,234.50%", "March 5, 2024"},
+		{"en-GB", "$64,231.12", "+1,234.50%", "5 March 2024"},
+		{"de-DE", "64.231,12\u00a0$", "+1.234,50%", "5. März 2024"},
+		{"es-ES", "64.231,12\u00a0$", "+1.234,50%", "5 de marzo de 2024"},
+		{"not a locale", "$64,231.12", "+1,234.50%", "March 5, 2024"},
+	}
+
+	for _, c := range cases {
+		f := newFormatter(c.locale)
+		if got := f.Price(64231.123); got != c.price {
+			t.Errorf("%s: Price = %q, want %q", c.locale, got, c.price)
+		}
+		if got := f.Percent(1234.5); got != c.percent {
+			t.Errorf("%s: Percent = %q, want %q", c.locale, got, c.percent)
+		}
+		if got := f.Date(day); got != c.date {
+			t.Errorf("%s: Date = %q, want %q", c.locale, got, c.date)
+		}
+	}
+}
+
+func TestPromptsIgnorePostLocale(t *testing.T) {
+	saved := postFormatter
+	defer func() { postFormatter = saved }()
+	postFormatter = newFormatter("de-DE")
+
+	summary := TechnicalSummary{Coin: "BTC", Days: 90, SMA20: 64231.12}
+	for _, v := range []*float64{&summary.SMA50, &summary.EMA12, &summary.EMA26, &summary.RSI14, &summary.MACD, &summary.MACDSignal, &summary.MACDHistogram,
+		&summary.BollingerUpper, &summary.BollingerMiddle, &summary.BollingerLower, &summary.Volatility, &summary.MaxDrawdown} {
+		*v = math.NaN()
+	}
+
+	if rows := summary.Rows(); len(rows) != 1 || rows[0].Value != "64.231,12\u00a0$" {
+		t.Errorf("expected the post locale in rows, got %+v", rows)
+	}
+	if prompt := summary.Prompt(); !strings.Contains(prompt, "SMA (20d): $64,231.12") {
+		t.Errorf("expected the prompt locale in %q", prompt)
+	}
+}
+
+func TestPromptPrices(t *testing.T) {
+	got := promptPrices([]CoinConversion{{value: 0.00002345}, {value: 64231.12}})
+	if got != "$0.00002345; $64,231.12" {
+		t.Errorf("unexpected prices %q", got)
+	}
+}
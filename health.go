@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// when this process started, so jobs aren't reported overdue before they've had a chance to run
+var processStarted = time.Now()
+
+// the scheduler of the serve command, which /readyz checks is still scheduling jobs
+var scheduler gocron.Scheduler
+
+// how long /readyz waits for its checks, shorter than the healthcheck timeout in compose.yml
+var readinessTimeout = 5 * time.Second
+
+// the outcome of one readiness check
+type Check struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func checkResult(err error) Check {
+	if err != nil {
+		return Check{Error: err.Error()}
+	}
+	return Check{OK: true}
+}
+
+// whether a job has succeeded recently enough. It's overdue once it has missed two runs in a row
+type JobCheck struct {
+	Check
+	LastSuccess *time.Time `json:"last_success"`
+	Age         string     `json:"age,omitempty"`
+	OverdueAt   *time.Time `json:"overdue_at,omitempty"`
+}
+
+type Readiness struct {
+	Ready     bool                `json:"ready"`
+	Database  Check               `json:"database"`
+	Scheduler Check               `json:"scheduler"`
+	Ghost     Check               `json:"ghost"`
+	Jobs      map[string]JobCheck `json:"jobs"`
+}
+
+// runs every check at once, each giving up when ctx is done
+func checkReadiness(ctx context.Context) Readiness {
+	var r Readiness
+	var wg sync.WaitGroup
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		r.Database = checkResult(checkDatabase(ctx))
+	}()
+	go func() {
+		defer wg.Done()
+		r.Scheduler = checkResult(checkScheduler())
+	}()
+	go func() {
+		defer wg.Done()
+		r.Ghost = checkResult(checkGhost(ctx))
+	}()
+	go func() {
+		defer wg.Done()
+		r.Jobs = checkJobs(ctx, time.Now())
+	}()
+	wg.Wait()
+
+	r.Ready = r.Database.OK && r.Scheduler.OK && r.Ghost.OK
+	for _, job := range r.Jobs {
+		r.Ready = r.Ready && job.OK
+	}
+	return r
+}
+
+func checkDatabase(ctx context.Context) error {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	return db.Ping(ctx)
+}
+
+// the scheduler answers for each of its jobs with the time of their next run, unless it has stopped
+func checkScheduler() error {
+	if isDraining() {
+		return ErrShuttingDown
+	}
+	if scheduler == nil {
+		return errors.New("scheduler not started")
+	}
+
+	for _, job := range scheduler.Jobs() {
+		next, err := job.NextRun()
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name(), err)
+		}
+		if next.IsZero() {
+			return fmt.Errorf("job %s has no next run", job.Name())
+		}
+	}
+	return nil
+}
+
+// the site endpoint of the admin api doesn't need authentication
+func checkGhost(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", config.GhostAdminURL+"site/", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := httpClient(config.Timeouts.Ghost).Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("unexpected status %s", res.Status)
+	}
+	return nil
+}
+
+// checks every enabled job against the time of its last successful run, by any replica
+func checkJobs(ctx context.Context, now time.Time) map[string]JobCheck {
+	checks := map[string]JobCheck{}
+	last, err := getLastSuccessfulRuns(ctx)
+	if err != nil {
+		log.Println(err)
+	}
+
+	schedules := config.Jobs.byName()
+	for _, job := range jobs {
+		schedule := schedules[job.Name]
+		if !schedule.Enabled {
+			continue
+		}
+		if err != nil {
+			checks[job.Name] = JobCheck{Check: Check{Error: "unable to read job runs"}}
+			continue
+		}
+		checks[job.Name] = checkJob(*schedule, last[job.Name], now)
+	}
+
+	return checks
+}
+
+func checkJob(schedule JobSchedule, lastSuccess time.Time, now time.Time) JobCheck {
+	var check JobCheck
+	since := processStarted
+	if !lastSuccess.IsZero() {
+		check.LastSuccess = &lastSuccess
+		check.Age = now.Sub(lastSuccess).Round(time.Second).String()
+		if lastSuccess.After(since) {
+			since = lastSuccess
+		}
+	}
+
+	due, err := schedule.next(since)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	overdue, err := schedule.next(due)
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	check.OverdueAt = &overdue
+	check.OK = now.Before(overdue)
+	if !check.OK {
+		check.Error = "missed its last two runs"
+	}
+	return check
+}
+
+// when each job last finished successfully
+func getLastSuccessfulRuns(ctx context.Context) (map[string]time.Time, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT job, MAX(finished_at) FROM job_runs WHERE status = $1 GROUP BY job", RunSucceeded)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	last := map[string]time.Time{}
+	for rows.Next() {
+		var job string
+		var finished time.Time
+		err = rows.Scan(&job, &finished)
+		if err != nil {
+			return nil, err
+		}
+		last[job] = finished
+	}
+
+	return last, rows.Err()
+}
+
+// GET /healthz reports that the process is up and serving, regardless of its dependencies
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "alive"})
+}
+
+// GET /readyz reports the database, the scheduler, ghost and how recently each job succeeded,
+// responding 503 if any of them is failing
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	readiness := checkReadiness(ctx)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(readiness)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckJob(t *testing.T) {
+	started := processStarted
+	defer func() { processStarted = started }()
+
+	now := time.Date(2024, 3, 6, 15, 0, 0, 0, time.UTC)
+	processStarted = now.AddDate(0, -2, 0)
+	every30m := JobSchedule{Enabled: true, Schedule: "30m"}
+	weekly := JobSchedule{Enabled: true, Schedule: "0 12 * * 0", Timezone: "UTC"}
+
+	tests := []struct {
+		name        string
+		schedule    JobSchedule
+		lastSuccess time.Time
+		ok          bool
+	}{
+		{"recent", every30m, now.Add(-10 * time.Minute), true},
+		{"missed two runs", every30m, now.Add(-61 * time.Minute), false},
+		{"never succeeded", every30m, time.Time{}, false},
+		{"weekly missed one run", weekly, time.Date(2024, 2, 25, 12, 5, 0, 0, time.UTC), true},
+		{"weekly missed two runs", weekly, time.Date(2024, 2, 18, 12, 5, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		check := checkJob(tt.schedule, tt.lastSuccess, now)
+		if check.OK != tt.ok {
+			t.Errorf("%s: ok = %v, want %v (%+v)", tt.name, check.OK, tt.ok, check)
+		}
+		if !tt.ok && check.Error == "" {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	// a process that only just started gives its jobs time to run, however long ago they last succeeded
+	processStarted = now.Add(-10 * time.Minute)
+	if check := checkJob(every30m, now.Add(-48*time.Hour), now); !check.OK {
+		t.Errorf("expected jobs of a new process to be ok: %+v", check)
+	}
+}
+
+func TestCheckScheduler(t *testing.T) {
+	s := scheduler
+	defer func() { scheduler = s }()
+
+	scheduler = nil
+	if err := checkScheduler(); err == nil {
+		t.Error("expected an error before the scheduler is started")
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	w := httptest.NewRecorder()
+	healthzHandler(w, httptest.NewRequest("GET", "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,197 @@
+// Package indicators computes technical analysis indicators over a price history.
+//
+// Series returned by this package are aligned with their input: the value at index i
+// is the indicator as of the input value at index i, and NaN where there is not yet
+// enough history to compute it.
+package indicators
+
+import "math"
+
+// SMA returns the simple moving average over period values.
+func SMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 {
+		return out
+	}
+
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+
+	return out
+}
+
+// EMA returns the exponential moving average over period values, seeded with the SMA of the first period values.
+func EMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) < period {
+		return out
+	}
+
+	k := 2 / float64(period+1)
+	out[period-1] = SMA(values[:period], period)[period-1]
+	for i := period; i < len(values); i++ {
+		out[i] = values[i]*k + out[i-1]*(1-k)
+	}
+
+	return out
+}
+
+// RSI returns Wilder's relative strength index over period values, from 0 to 100.
+func RSI(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period <= 0 || len(values) <= period {
+		return out
+	}
+
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := values[i] - values[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+
+	for i := period + 1; i < len(values); i++ {
+		change := values[i] - values[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		if gain == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD returns the difference between the fast and slow EMA, its signal line and the histogram between them.
+func MACD(values []float64, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	fastEMA := EMA(values, fast)
+	slowEMA := EMA(values, slow)
+
+	macd = nanSeries(len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+
+	// the signal line starts once the macd itself is defined
+	start := 0
+	for start < len(macd) && math.IsNaN(macd[start]) {
+		start++
+	}
+	signalLine = nanSeries(len(values))
+	copy(signalLine[start:], EMA(macd[start:], signal))
+
+	histogram = nanSeries(len(values))
+	for i := range values {
+		histogram[i] = macd[i] - signalLine[i]
+	}
+
+	return macd, signalLine, histogram
+}
+
+// Bollinger returns the SMA over period values and the bands k standard deviations above and below it.
+func Bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper = nanSeries(len(values))
+	lower = nanSeries(len(values))
+
+	for i := range values {
+		if math.IsNaN(middle[i]) {
+			continue
+		}
+		d := stddev(values[i-period+1:i+1], middle[i])
+		upper[i] = middle[i] + k*d
+		lower[i] = middle[i] - k*d
+	}
+
+	return middle, upper, lower
+}
+
+// Volatility returns the standard deviation of log returns, annualized by periodsPerYear.
+// Pass 1 for the volatility per period.
+func Volatility(values []float64, periodsPerYear float64) float64 {
+	if len(values) < 3 {
+		return math.NaN()
+	}
+
+	returns := make([]float64, 0, len(values)-1)
+	for i := 1; i < len(values); i++ {
+		if values[i-1] <= 0 || values[i] <= 0 {
+			continue
+		}
+		returns = append(returns, math.Log(values[i]/values[i-1]))
+	}
+	if len(returns) < 2 {
+		return math.NaN()
+	}
+
+	return stddev(returns, mean(returns)) * math.Sqrt(periodsPerYear)
+}
+
+// MaxDrawdown returns the largest fall from a peak to a later trough, as a fraction of the peak.
+func MaxDrawdown(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+
+	peak, drawdown := values[0], 0.0
+	for _, v := range values {
+		peak = math.Max(peak, v)
+		if peak > 0 {
+			drawdown = math.Max(drawdown, (peak-v)/peak)
+		}
+	}
+
+	return drawdown
+}
+
+// Last returns the latest value of a series, or NaN if it is empty.
+func Last(series []float64) float64 {
+	if len(series) == 0 {
+		return math.NaN()
+	}
+	return series[len(series)-1]
+}
+
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// population standard deviation around the given mean
+func stddev(values []float64, m float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
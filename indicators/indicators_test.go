@@ -0,0 +1,72 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestSMA(t *testing.T) {
+	got := SMA([]float64{1, 2, 3, 4, 5}, 3)
+	if !math.IsNaN(got[1]) || !near(got[2], 2) || !near(got[4], 4) {
+		t.Errorf("unexpected sma %v", got)
+	}
+}
+
+func TestEMA(t *testing.T) {
+	got := EMA([]float64{2, 4, 6, 8}, 3)
+	// seeded with the sma of 2, 4, 6 then k = 0.5
+	if !math.IsNaN(got[1]) || !near(got[2], 4) || !near(got[3], 6) {
+		t.Errorf("unexpected ema %v", got)
+	}
+}
+
+func TestRSI(t *testing.T) {
+	rising := RSI([]float64{1, 2, 3, 4, 5, 6}, 3)
+	if !near(Last(rising), 100) {
+		t.Errorf("expected rsi of 100 for a rising series, got %v", rising)
+	}
+
+	mixed := RSI([]float64{10, 11, 10, 11, 10}, 2)
+	if v := Last(mixed); v <= 0 || v >= 100 {
+		t.Errorf("expected rsi between 0 and 100, got %v", v)
+	}
+}
+
+func TestMACD(t *testing.T) {
+	values := make([]float64, 40)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	macd, signal, hist := MACD(values, 12, 26, 9)
+	if !math.IsNaN(macd[24]) || math.IsNaN(macd[25]) || math.IsNaN(Last(signal)) {
+		t.Fatalf("unexpected macd alignment %v %v", macd, signal)
+	}
+	if Last(macd) <= 0 || !near(Last(hist), Last(macd)-Last(signal)) {
+		t.Errorf("unexpected macd %v signal %v histogram %v", Last(macd), Last(signal), Last(hist))
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	middle, upper, lower := Bollinger([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	if !near(Last(middle), 5) || !near(Last(upper), 9) || !near(Last(lower), 1) {
+		t.Errorf("unexpected bands %v %v %v", Last(middle), Last(upper), Last(lower))
+	}
+}
+
+func TestVolatilityAndDrawdown(t *testing.T) {
+	if v := Volatility([]float64{100, 100, 100, 100}, 365); !near(v, 0) {
+		t.Errorf("expected no volatility, got %v", v)
+	}
+	if !math.IsNaN(Volatility([]float64{1}, 1)) {
+		t.Error("expected NaN volatility for a single value")
+	}
+
+	if d := MaxDrawdown([]float64{100, 120, 90, 110, 60, 130}); !near(d, 0.5) {
+		t.Errorf("expected drawdown of 0.5, got %v", d)
+	}
+}
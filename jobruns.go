@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// what started a run
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+var ErrJobRunning = errors.New("job is already running")
+
+// one execution of a job, as stored in job_runs
+type JobRun struct {
+	ID         int64      `json:"id"`
+	Job        string     `json:"job"`
+	Trigger    string     `json:"trigger"`
+	Params     string     `json:"params,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Status     string     `json:"status"`
+	Items      int        `json:"items"`
+	Error      string     `json:"error,omitempty"`
+
+	// what a dry run triggered over http would have published, while this process remembers it
+	DryRun *DryRunOutput `json:"dry_run,omitempty"`
+}
+
+// how runs are locked and recorded, replaced in tests
+var (
+	lockRun        = lockJobRun
+	recordRunStart = insertJobRun
+	recordRunEnd   = finishJobRun
+)
+
+// records a run as started
+func beginJobRun(job, trigger, params string) (*JobRun, error) {
+	run := &JobRun{Job: job, Trigger: trigger, Params: params, StartedAt: time.Now(), Status: RunRunning}
+	return run, recordRunStart(run)
+}
+
+// records the outcome of the run
+func (run *JobRun) complete(runErr error) {
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = RunSucceeded
+	if runErr != nil {
+		run.Status = RunFailed
+		run.Error = runErr.Error()
+	}
+
+	log.Printf("Job %s %s in %s with %d items", run.Job, run.Status, finished.Sub(run.StartedAt).Round(time.Second), run.Items)
+	err := recordRunEnd(*run)
+	if err != nil {
+		log.Println("Error recording job run:", err)
+	}
+}
+
+// output of dry runs triggered over http kept for polling, the oldest are forgotten first
+var maxDryRunOutputs = 20
+
+// runs in progress by job name, kept apart by trigger. Scheduled jobs run in singleton mode,
+// and manual runs are refused while one of the same job is in progress, so there is at most one of each
+type runTracker struct {
+	wg      sync.WaitGroup // manual runs in progress
+	mu      sync.Mutex
+	runs    map[string]*JobRun
+	manual  map[string]*JobRun
+	dryRuns map[int64]*DryRun
+	dryIDs  []int64
+}
+
+var jobRuns = newRunTracker()
+
+func newRunTracker() *runTracker {
+	return &runTracker{runs: map[string]*JobRun{}, manual: map[string]*JobRun{}, dryRuns: map[int64]*DryRun{}}
+}
+
+func (t *runTracker) start(job string) {
+	params := ""
+	if config.DryRun {
+		params = "dry_run=true"
+	}
+
+	run, err := beginJobRun(job, TriggerSchedule, params)
+	if err != nil {
+		log.Println("Error recording job run:", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.runs[job] = run
+}
+
+// records how many items the run produced, such as posts published or prices fetched
+func (t *runTracker) setItems(job string, items int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if run, ok := t.runs[job]; ok {
+		run.Items = items
+	}
+}
+
+func (t *runTracker) finish(job string, runErr error) {
+	t.mu.Lock()
+	run, ok := t.runs[job]
+	delete(t.runs, job)
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	run.complete(runErr)
+}
+
+// starts a run outside the scheduler in the background and returns it once recorded, so its id can be polled.
+// Dry runs keep their output in memory, unless every run is dry in which case it's written to disk as usual
+func (t *runTracker) runManually(job, params string, dry bool, task func(context.Context) (int, error)) (*JobRun, error) {
+	t.mu.Lock()
+	if isDraining() {
+		t.mu.Unlock()
+		return nil, ErrShuttingDown
+	}
+	_, manual := t.manual[job]
+	_, scheduled := t.runs[job]
+	if manual || scheduled {
+		t.mu.Unlock()
+		return nil, ErrJobRunning
+	}
+
+	// a placeholder holds the slot while the run is locked and recorded, without holding up other jobs
+	t.manual[job] = &JobRun{Job: job, Trigger: TriggerManual, Status: RunRunning}
+	t.wg.Add(1)
+	t.mu.Unlock()
+
+	release := func() {
+		t.mu.Lock()
+		delete(t.manual, job)
+		t.mu.Unlock()
+		t.wg.Done()
+	}
+
+	// other instances only know about the run through its lock
+	unlock, err := lockRun(job, true)
+	if err != nil {
+		release()
+		if errors.Is(err, ErrJobLocked) {
+			return nil, ErrJobRunning
+		}
+		return nil, err
+	}
+
+	ctx := runContext
+	var d *DryRun
+	if config.DryRun {
+		d = newDryRun(config.DryRunDir, job)
+	} else if dry {
+		d = newDryRun("", job)
+	}
+	if d != nil {
+		ctx = withDryRun(ctx, d)
+		params = strings.TrimPrefix(params+"&dry_run=true", "&")
+	}
+
+	run, err := beginJobRun(job, TriggerManual, params)
+	if err != nil {
+		unlock()
+		release()
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.manual[job] = run
+	if d != nil {
+		t.keepDryRun(run.ID, d)
+	}
+	started := *run
+	t.mu.Unlock()
+
+	go func() {
+		defer release()
+		defer unlock()
+		items, err := task(ctx)
+
+		t.mu.Lock()
+		run.Items = items
+		t.mu.Unlock()
+
+		run.complete(err)
+	}()
+
+	return &started, nil
+}
+
+// waits for manual runs to finish. Scheduled runs are waited for by the scheduler
+func (t *runTracker) wait() {
+	t.wg.Wait()
+}
+
+// remembers the output of a dry run, forgetting the oldest beyond maxDryRunOutputs. Called with t.mu held
+func (t *runTracker) keepDryRun(id int64, d *DryRun) {
+	t.dryRuns[id] = d
+	t.dryIDs = append(t.dryIDs, id)
+	if len(t.dryIDs) > maxDryRunOutputs {
+		delete(t.dryRuns, t.dryIDs[0])
+		t.dryIDs = t.dryIDs[1:]
+	}
+}
+
+// output of a dry run so far, or nil if the run wasn't dry or is no longer remembered
+func (t *runTracker) dryRunOutput(id int64) *DryRunOutput {
+	t.mu.Lock()
+	d, ok := t.dryRuns[id]
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	out := d.Output()
+	return &out
+}
+
+// gocron options that record every run of the job in job_runs
+func (t *runTracker) jobOptions() []gocron.JobOption {
+	return []gocron.JobOption{
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithEventListeners(
+			gocron.BeforeJobRuns(func(_ uuid.UUID, name string) {
+				t.start(name)
+			}),
+			gocron.AfterJobRuns(func(_ uuid.UUID, name string) {
+				t.finish(name, nil)
+			}),
+			gocron.AfterJobRunsWithError(func(_ uuid.UUID, name string, err error) {
+				t.finish(name, err)
+			}),
+		),
+	}
+}
+
+// runs are recorded outside of their own context, so a cancelled run is still recorded as failed
+func insertJobRun(run *JobRun) error {
+	ctx, cancel := dbContext(context.Background())
+	defer cancel()
+	return db.QueryRow(ctx,
+		"INSERT INTO job_runs (job, trigger, params, started_at, status) VALUES ($1, $2, NULLIF($3, ''), $4, $5) RETURNING id",
+		run.Job, run.Trigger, run.Params, run.StartedAt, run.Status).Scan(&run.ID)
+}
+
+func finishJobRun(run JobRun) error {
+	if run.ID == 0 {
+		// the start was never recorded
+		return insertJobRunResult(run)
+	}
+
+	_, err := dbExec(context.Background(),
+		"UPDATE job_runs SET finished_at = $2, status = $3, items = $4, error = NULLIF($5, '') WHERE id = $1",
+		run.ID, run.FinishedAt, run.Status, run.Items, run.Error)
+	return err
+}
+
+func insertJobRunResult(run JobRun) error {
+	_, err := dbExec(context.Background(),
+		"INSERT INTO job_runs (job, trigger, params, started_at, finished_at, status, items, error) VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7, NULLIF($8, ''))",
+		run.Job, run.Trigger, run.Params, run.StartedAt, run.FinishedAt, run.Status, run.Items, run.Error)
+	return err
+}
+
+const jobRunColumns = "id, job, trigger, COALESCE(params, ''), started_at, finished_at, status, items, COALESCE(error, '')"
+
+// scan destinations in the order of jobRunColumns
+func (run *JobRun) fields() []interface{} {
+	return []interface{}{&run.ID, &run.Job, &run.Trigger, &run.Params, &run.StartedAt, &run.FinishedAt, &run.Status, &run.Items, &run.Error}
+}
+
+func getJobRun(ctx context.Context, id int64) (JobRun, error) {
+	var run JobRun
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	err := db.QueryRow(ctx, "SELECT "+jobRunColumns+" FROM job_runs WHERE id = $1", id).Scan(run.fields()...)
+	return run, err
+}
+
+// most recent runs, newest first, of one job or of all jobs if job is empty
+func getJobRuns(ctx context.Context, job string, limit int) ([]JobRun, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx,
+		`SELECT `+jobRunColumns+` FROM job_runs WHERE $1 = '' OR job = $1 ORDER BY started_at DESC LIMIT $2`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []JobRun{}
+	for rows.Next() {
+		var run JobRun
+		err = rows.Scan(run.fields()...)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// GET /jobs/runs?job=rss&limit=20 lists recent job runs as json
+func jobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	runs, err := getJobRuns(r.Context(), r.URL.Query().Get("job"), limit)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "unable to read job runs", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runs)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// records runs in memory instead of the database, and takes no locks
+func fakeJobRunStore(t *testing.T) func() []JobRun {
+	savedLock, savedStart, savedEnd := lockRun, recordRunStart, recordRunEnd
+	t.Cleanup(func() { lockRun, recordRunStart, recordRunEnd = savedLock, savedStart, savedEnd })
+
+	var mu sync.Mutex
+	var nextID int64
+	var ended []JobRun
+	lockRun = func(string, bool) (func(), error) { return func() {}, nil }
+	recordRunStart = func(run *JobRun) error {
+		mu.Lock()
+		defer mu.Unlock()
+		nextID++
+		run.ID = nextID
+		return nil
+	}
+	recordRunEnd = func(run JobRun) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ended = append(ended, run)
+		return nil
+	}
+
+	return func() []JobRun {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]JobRun{}, ended...)
+	}
+}
+
+func TestRunTrackerScheduled(t *testing.T) {
+	ended := fakeJobRunStore(t)
+	tracker := newRunTracker()
+
+	tracker.start("rss")
+	tracker.setItems("rss", 3)
+	tracker.setItems("other", 5)
+	tracker.finish("rss", errors.New("feed down"))
+	tracker.finish("other", nil)
+
+	runs := ended()
+	if len(runs) != 1 {
+		t.Fatalf("expected one recorded run, got %+v", runs)
+	}
+	run := runs[0]
+	if run.Job != "rss" || run.Trigger != TriggerSchedule || run.Status != RunFailed || run.Items != 3 || run.Error != "feed down" || run.FinishedAt == nil {
+		t.Errorf("unexpected run %+v", run)
+	}
+	if len(tracker.runs) != 0 {
+		t.Errorf("finished run still tracked: %v", tracker.runs)
+	}
+}
+
+func TestRunManually(t *testing.T) {
+	ended := fakeJobRunStore(t)
+	tracker := newRunTracker()
+
+	// the lock is slow to take, which holds the slot of the job but not of the others
+	locked := make(chan struct{})
+	lockRun = func(job string, _ bool) (func(), error) {
+		if job == "rss" {
+			<-locked
+		}
+		return func() {}, nil
+	}
+
+	release := make(chan struct{})
+	task := func(context.Context) (int, error) {
+		<-release
+		return 2, nil
+	}
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := tracker.runManually("rss", "", false, task)
+		first <- err
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		tracker.mu.Lock()
+		_, reserved := tracker.manual["rss"]
+		tracker.mu.Unlock()
+		if reserved {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("run never reserved its slot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := tracker.runManually("rss", "", false, task); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("second run = %v, want ErrJobRunning", err)
+	}
+	other, err := tracker.runManually("markets", "", true, task)
+	if err != nil {
+		t.Fatalf("other job = %v", err)
+	}
+	if tracker.dryRunOutput(other.ID) == nil {
+		t.Error("expected the dry run output to be kept")
+	}
+
+	close(locked)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	tracker.wait()
+
+	runs := ended()
+	if len(runs) != 2 || runs[0].Items != 2 || runs[0].Status != RunSucceeded || runs[0].Trigger != TriggerManual {
+		t.Errorf("unexpected runs %+v", runs)
+	}
+	if len(tracker.manual) != 0 {
+		t.Errorf("finished runs still tracked: %v", tracker.manual)
+	}
+}
+
+func TestRunManuallyLocked(t *testing.T) {
+	ended := fakeJobRunStore(t)
+	tracker := newRunTracker()
+	lockRun = func(string, bool) (func(), error) { return nil, ErrJobLocked }
+
+	_, err := tracker.runManually("rss", "", false, func(context.Context) (int, error) { return 0, nil })
+	if !errors.Is(err, ErrJobRunning) {
+		t.Errorf("run = %v, want ErrJobRunning", err)
+	}
+	if len(tracker.manual) != 0 || len(ended()) != 0 {
+		t.Error("expected the slot released and nothing recorded")
+	}
+	tracker.wait()
+}
+
+func TestKeepDryRun(t *testing.T) {
+	saved := maxDryRunOutputs
+	defer func() { maxDryRunOutputs = saved }()
+	maxDryRunOutputs = 2
+
+	tracker := newRunTracker()
+	for id := int64(1); id <= 3; id++ {
+		tracker.keepDryRun(id, newDryRun("", "rss"))
+	}
+
+	if tracker.dryRunOutput(1) != nil {
+		t.Error("expected the oldest output to be forgotten")
+	}
+	if tracker.dryRunOutput(2) == nil || tracker.dryRunOutput(3) == nil {
+		t.Error("expected the newest outputs to be kept")
+	}
+	if len(tracker.dryIDs) != 2 {
+		t.Errorf("unexpected ids %v", tracker.dryIDs)
+	}
+}
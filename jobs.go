@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	_ "time/tzdata" // job timezones must resolve in slim containers without zoneinfo
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/robfig/cron/v3"
+)
+
+// when a job runs. Schedule is either a duration such as "30m" or a cron expression such as "0 12 * * 0"
+type JobSchedule struct {
+	Enabled  bool   `yaml:"enabled"`
+	Schedule string `yaml:"schedule"`
+	Timezone string `yaml:"timezone"` // IANA name such as "America/New_York" for cron schedules, the container's local time if empty
+}
+
+type JobsConfig struct {
+	Market      JobSchedule `yaml:"market"`
+	Predictions JobSchedule `yaml:"predictions"`
+	RSS         JobSchedule `yaml:"rss"`
+	Correlation JobSchedule `yaml:"correlation"`
+}
+
+func defaultJobsConfig() JobsConfig {
+	return JobsConfig{
+		Market:      JobSchedule{Enabled: true, Schedule: "30m"},
+		Predictions: JobSchedule{Enabled: true, Schedule: "0 12 * * 0"}, // sundays at noon
+		RSS:         JobSchedule{Enabled: true, Schedule: "4h"},
+		Correlation: JobSchedule{Enabled: true, Schedule: "0 12 1 * *"}, // the first of the month at noon
+	}
+}
+
+// schedules by job name, which is also the prefix of their environment variables such as rssSchedule
+func (j *JobsConfig) byName() map[string]*JobSchedule {
+	return map[string]*JobSchedule{
+		"market":      &j.Market,
+		"predictions": &j.Predictions,
+		"rss":         &j.RSS,
+		"correlation": &j.Correlation,
+	}
+}
+
+// a task the scheduler runs. It returns how many items it produced, such as posts published or prices fetched
+type Job struct {
+	Name string
+	Task func(ctx context.Context) (int, error)
+}
+
+var jobs = []Job{
+	{Name: "market", Task: func(ctx context.Context) (int, error) {
+		log.Println("Checking market values")
+		return getAllCoinValues(ctx)
+	}},
+	{Name: "predictions", Task: func(ctx context.Context) (int, error) {
+		log.Println("Creating predictions")
+		return postPredictions(ctx)
+	}},
+	{Name: "rss", Task: func(ctx context.Context) (int, error) {
+		log.Println("Checking rss")
+		return checkFeedsAndPost(ctx)
+	}},
+	{Name: "correlation", Task: func(ctx context.Context) (int, error) {
+		log.Println("Analyzing sentiment correlation")
+		err := postSentimentCorrelation(ctx)
+		if errors.Is(err, ErrInsufficientData) {
+			// expected until a month of headlines has been collected
+			log.Println(err)
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}},
+}
+
+// runs the task, keeping its item count for the run record
+func (j Job) run() error {
+	// in lock mode gocron already holds the job's lock. Otherwise it's taken here,
+	// so a manual run on this or another instance can't run alongside
+	if config.JobCoordination != CoordinationLock {
+		unlock, err := lockJobRun(j.Name, false)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	ctx := runContext
+	if config.DryRun {
+		ctx = withDryRun(ctx, newDryRun(config.DryRunDir, j.Name))
+	}
+
+	items, err := j.Task(ctx)
+	jobRuns.setItems(j.Name, items)
+	return err
+}
+
+// parses the schedule into a gocron job definition
+func (s JobSchedule) definition() (gocron.JobDefinition, error) {
+	every, err := time.ParseDuration(s.Schedule)
+	if err == nil {
+		if every <= 0 {
+			return nil, fmt.Errorf("interval %s must be positive", s.Schedule)
+		}
+		// an interval runs at the same times in every timezone
+		if s.Timezone != "" {
+			return nil, fmt.Errorf("timezone %s only applies to cron schedules, not the interval %s", s.Timezone, s.Schedule)
+		}
+		return gocron.DurationJob(every), nil
+	}
+
+	expr, _, err := s.cron()
+	if err != nil {
+		return nil, err
+	}
+
+	return gocron.CronJob(expr, false), nil
+}
+
+// the schedule as a cron expression in its timezone, for schedules that aren't a duration
+func (s JobSchedule) cron() (string, cron.Schedule, error) {
+	expr := strings.TrimSpace(s.Schedule)
+	if s.Timezone != "" {
+		_, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			return "", nil, err
+		}
+		expr = "CRON_TZ=" + s.Timezone + " " + expr
+	}
+
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return "", nil, fmt.Errorf("%q is neither a duration nor a cron expression: %w", s.Schedule, err)
+	}
+
+	return expr, schedule, nil
+}
+
+// when the job is next due after the given time
+func (s JobSchedule) next(after time.Time) (time.Time, error) {
+	every, err := time.ParseDuration(s.Schedule)
+	if err == nil {
+		return after.Add(every), nil
+	}
+
+	_, schedule, err := s.cron()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(after), nil
+}
+
+// adds every enabled job to the scheduler
+func scheduleJobs(s gocron.Scheduler, schedules JobsConfig) error {
+	byName := schedules.byName()
+	for _, job := range jobs {
+		schedule := byName[job.Name]
+		if !schedule.Enabled {
+			log.Println("Job disabled:", job.Name)
+			continue
+		}
+
+		definition, err := schedule.definition()
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+
+		options := append(jobRuns.jobOptions(), gocron.WithName(job.Name))
+		_, err = s.NewJob(definition, gocron.NewTask(job.run), options...)
+		if err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJobScheduleDefinition(t *testing.T) {
+	valid := []JobSchedule{
+		{Schedule: "30m"},
+		{Schedule: "0 12 * * 0"},
+		{Schedule: "0 12 * * 0", Timezone: "America/New_York"},
+	}
+	for _, s := range valid {
+		if _, err := s.definition(); err != nil {
+			t.Errorf("%+v: %v", s, err)
+		}
+	}
+
+	invalid := []JobSchedule{
+		{Schedule: "-5m"},
+		{Schedule: "sometimes"},
+		{Schedule: "0 12 * *"},
+		{Schedule: "0 12 * * 0", Timezone: "Mars/Olympus_Mons"},
+		{Schedule: "30m", Timezone: "Mars/Olympus_Mons"},
+		{Schedule: "30m", Timezone: "UTC"},
+	}
+	for _, s := range invalid {
+		if _, err := s.definition(); err == nil {
+			t.Errorf("%+v: expected an error", s)
+		}
+	}
+}
+
+func TestJobsConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte("databaseUrl: postgres://writer@localhost/writer\ngeminiKey: g\ncoinmarketKey: c\nadminId: a\nadminKey: 0a\njobs:\n  rss:\n    schedule: 2h\n  correlation:\n    schedule: never\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("configFile", file)
+	t.Setenv("predictionsEnabled", "false")
+
+	_, err = loadConfig()
+	if err == nil {
+		t.Fatal("expected an error for the correlation schedule")
+	}
+
+	t.Setenv("correlationEnabled", "no")
+	if _, err := loadConfig(); err == nil {
+		t.Fatal("expected an error for a malformed enabled flag")
+	}
+
+	t.Setenv("correlationEnabled", "false")
+	c, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Jobs.RSS.Enabled || c.Jobs.RSS.Schedule != "2h" {
+		t.Errorf("the file should only override the rss schedule: %+v", c.Jobs.RSS)
+	}
+	if c.Jobs.Predictions.Enabled || c.Jobs.Market.Schedule != "30m" {
+		t.Errorf("unexpected jobs %+v", c.Jobs)
+	}
+}
@@ -0,0 +1,123 @@
+// Package lexicon scores the sentiment of crypto and finance headlines from a word list,
+// without any network calls or external dependencies.
+package lexicon
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Words and phrases with their sentiment, from -1 (very negative) to 1 (very positive).
+// Phrases are matched on whole words, longest first.
+var Words = map[string]float64{
+	// price action
+	"surge": 0.8, "surges": 0.8, "surging": 0.8, "soar": 0.8, "soars": 0.8, "soaring": 0.8,
+	"rally": 0.7, "rallies": 0.7, "rallying": 0.7, "jump": 0.6, "jumps": 0.6, "gain": 0.5, "gains": 0.5,
+	"rise": 0.4, "rises": 0.4, "rising": 0.4, "climb": 0.4, "climbs": 0.4, "rebound": 0.5, "rebounds": 0.5,
+	"recover": 0.4, "recovers": 0.4, "recovery": 0.4, "breakout": 0.6, "bullish": 0.7, "bull": 0.5,
+	"all-time high": 0.8, "record high": 0.8, "new high": 0.7, "moon": 0.6,
+	"plunge": -0.8, "plunges": -0.8, "plunging": -0.8, "crash": -0.9, "crashes": -0.9, "crashing": -0.9,
+	"tumble": -0.7, "tumbles": -0.7, "slump": -0.7, "slumps": -0.7, "drop": -0.5, "drops": -0.5,
+	"fall": -0.4, "falls": -0.4, "falling": -0.4, "decline": -0.4, "declines": -0.4, "slide": -0.5, "slides": -0.5,
+	"sell-off": -0.7, "selloff": -0.7, "bearish": -0.7, "bear": -0.5, "correction": -0.4, "capitulation": -0.8,
+	"liquidation": -0.6, "liquidations": -0.6, "liquidated": -0.6, "new low": -0.7, "dump": -0.6, "dumps": -0.6,
+
+	// adoption and business
+	"adoption": 0.6, "adopts": 0.6, "approval": 0.7, "approved": 0.7, "approves": 0.7, "launch": 0.4, "launches": 0.4,
+	"partnership": 0.5, "partners": 0.4, "integration": 0.4, "integrates": 0.4, "upgrade": 0.4, "inflows": 0.5,
+	"etf approval": 0.8, "institutional": 0.3, "milestone": 0.4, "growth": 0.4, "profit": 0.4, "record": 0.3,
+	"outflows": -0.5, "delisting": -0.7, "delists": -0.7, "bankruptcy": -0.9, "bankrupt": -0.9, "insolvent": -0.9,
+	"layoffs": -0.5, "losses": -0.5, "loss": -0.4, "delay": -0.3, "delays": -0.3, "delayed": -0.3,
+
+	// security and regulation
+	"hack": -0.9, "hacked": -0.9, "exploit": -0.8, "exploited": -0.8, "breach": -0.8, "stolen": -0.8, "theft": -0.8,
+	"scam": -0.8, "fraud": -0.9, "rug pull": -0.9, "ponzi": -0.9, "lawsuit": -0.6, "sues": -0.6, "sued": -0.6,
+	"charges": -0.5, "charged": -0.5, "indicted": -0.7, "ban": -0.7, "bans": -0.7, "banned": -0.7, "crackdown": -0.7,
+	"investigation": -0.5, "probe": -0.5, "fine": -0.4, "fined": -0.5, "penalty": -0.5, "sanctions": -0.5,
+	"outage": -0.6, "halted": -0.6, "halts": -0.6, "vulnerability": -0.6, "fears": -0.5, "fear": -0.5, "warning": -0.4,
+	"risk": -0.3, "risks": -0.3, "uncertainty": -0.4, "volatile": -0.2,
+	"settlement": 0.3, "dismissed": 0.7, "wins": 0.6, "win": 0.5, "victory": 0.6, "clarity": 0.4, "legal": 0.2,
+	"secure": 0.3, "optimism": 0.6, "optimistic": 0.6, "confidence": 0.4, "strong": 0.4, "strength": 0.4,
+}
+
+// words that flip the sentiment of the next few words
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "without": true, "isn't": true, "aren't": true, "wasn't": true,
+	"won't": true, "can't": true, "cannot": true, "doesn't": true, "don't": true, "didn't": true, "fails": true,
+	"failed": true, "despite": true, "avoids": true, "avoided": true, "denies": true, "denied": true,
+}
+
+const (
+	negationWindow = 3     // words after a negator that it applies to
+	negationFactor = -0.75 // negated sentiment is reversed and softened
+	normalization  = 2.0   // larger values need more matches to approach -1 or 1
+	maxPhraseWords = 3
+)
+
+// Result is the sentiment of a text.
+type Result struct {
+	Score   float64  // -1.0 to 1.0
+	Matches []string // words and phrases that contributed, negated ones prefixed with "not "
+}
+
+// Score rates the sentiment of a text from -1.0 to 1.0.
+func Score(text string) Result {
+	tokens := tokenize(text)
+	result := Result{}
+
+	sum := 0.0
+	negatedUntil := -1
+	for i := 0; i < len(tokens); {
+		if negators[tokens[i]] {
+			negatedUntil = i + negationWindow
+			i++
+			continue
+		}
+
+		phrase, weight, n := match(tokens[i:])
+		if n == 0 {
+			i++
+			continue
+		}
+
+		if i <= negatedUntil {
+			weight *= negationFactor
+			phrase = "not " + phrase
+		}
+		sum += weight
+		result.Matches = append(result.Matches, phrase)
+		i += n
+	}
+
+	result.Score = sum / math.Sqrt(sum*sum+normalization)
+	return result
+}
+
+// longest phrase from the lexicon at the start of tokens, and how many tokens it spans
+func match(tokens []string) (string, float64, int) {
+	for n := min(maxPhraseWords, len(tokens)); n > 0; n-- {
+		phrase := strings.Join(tokens[:n], " ")
+		if weight, ok := Words[phrase]; ok {
+			return phrase, weight, n
+		}
+	}
+	return "", 0, 0
+}
+
+// lower case words, keeping hyphens and apostrophes inside them
+func tokenize(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "’", "'")
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '\''
+	})
+
+	tokens := words[:0]
+	for _, w := range words {
+		w = strings.Trim(w, "-'")
+		if w != "" {
+			tokens = append(tokens, w)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,49 @@
+package lexicon
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	cases := []struct {
+		text string
+		sign int
+	}{
+		{"Bitcoin surges to all-time high as ETF inflows grow", 1},
+		{"Exchange hacked, $40M stolen from hot wallets", -1},
+		{"SEC lawsuit against Ripple dismissed", 1},
+		{"Ethereum price does not crash despite outage", 1},
+		{"Ledger publishes quarterly report", 0},
+		{"", 0},
+	}
+
+	for _, c := range cases {
+		got := Score(c.text)
+		if sign(got.Score) != c.sign {
+			t.Errorf("%q: got %v (%v), want sign %d", c.text, got.Score, got.Matches, c.sign)
+		}
+		if got.Score < -1 || got.Score > 1 {
+			t.Errorf("%q: score %v out of range", c.text, got.Score)
+		}
+	}
+}
+
+func TestPhrasesAndNegation(t *testing.T) {
+	got := Score("Bitcoin hits record high")
+	if len(got.Matches) != 1 || got.Matches[0] != "record high" {
+		t.Errorf("expected the longest phrase to match, got %v", got.Matches)
+	}
+
+	got = Score("Regulators won't ban staking")
+	if len(got.Matches) != 1 || got.Matches[0] != "not ban" || got.Score <= 0 {
+		t.Errorf("expected a negated ban, got %v %v", got.Matches, got.Score)
+	}
+}
+
+func sign(v float64) int {
+	if v > 0 {
+		return 1
+	}
+	if v < 0 {
+		return -1
+	}
+	return 0
+}
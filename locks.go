@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// how replicas share the scheduled jobs. Locking per job suits cron schedules, duration timers drift apart between instances
+const (
+	CoordinationLeader = "leader" // one elected instance runs every job, the others are hot standbys
+	CoordinationLock   = "lock"   // any instance may run a job, each run holds a lock on the job
+	CoordinationNone   = "none"   // every instance runs every job, for a single container
+)
+
+var ErrNotLeader = errors.New("another instance is the leader")
+var ErrJobLocked = errors.New("job is running or recently ran on another instance")
+
+// a locked run counts for this long, so an instance whose timer fires a few seconds late doesn't repeat it
+var lockSplay = time.Minute
+
+// advisory lock ids are 64 bit integers, derived from a name shared by every replica
+func advisoryLockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("ghost-writer/" + name))
+	return int64(h.Sum64())
+}
+
+// tries to take a session advisory lock, returning the connection that holds it
+func tryAdvisoryLock(ctx context.Context, pool *pgxpool.Pool, key int64) (*pgxpool.Conn, bool, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	err = conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Release()
+		return nil, false, err
+	}
+
+	return conn, true, nil
+}
+
+// releases the session advisory lock and returns the connection to the pool
+func releaseAdvisoryLock(ctx context.Context, conn *pgxpool.Conn, key int64) error {
+	_, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", key)
+	if err != nil {
+		// closing the session is the only other way to drop the lock
+		conn.Hijack().Close(ctx)
+		return err
+	}
+
+	conn.Release()
+	return nil
+}
+
+// a gocron Elector backed by a postgres advisory lock. The leader keeps a connection open holding the lock,
+// if it dies postgres ends the session and a standby takes over at its next scheduled job
+type AdvisoryElector struct {
+	pool *pgxpool.Pool
+	key  int64
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+}
+
+func newAdvisoryElector(pool *pgxpool.Pool) *AdvisoryElector {
+	return &AdvisoryElector{pool: pool, key: advisoryLockKey("leader")}
+}
+
+func (e *AdvisoryElector) IsLeader(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn != nil {
+		// the lock lasts as long as the session
+		err := e.conn.Ping(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Println("Lost leadership:", err)
+		e.conn.Hijack().Close(ctx)
+		e.conn = nil
+	}
+
+	conn, acquired, err := tryAdvisoryLock(ctx, e.pool, e.key)
+	if err != nil {
+		return fmt.Errorf("electing leader: %w", err)
+	}
+	if !acquired {
+		return ErrNotLeader
+	}
+
+	log.Println("Elected leader, running scheduled jobs")
+	e.conn = conn
+	return nil
+}
+
+// steps down so a standby can take over without waiting for this session to end
+func (e *AdvisoryElector) Resign(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.conn == nil {
+		return nil
+	}
+	err := releaseAdvisoryLock(ctx, e.conn, e.key)
+	e.conn = nil
+	return err
+}
+
+// a gocron Locker backed by postgres advisory locks, one per job name
+type AdvisoryLocker struct {
+	pool *pgxpool.Pool
+}
+
+type advisoryLock struct {
+	conn *pgxpool.Conn
+	key  int64
+}
+
+func (l AdvisoryLocker) Lock(ctx context.Context, job string) (gocron.Lock, error) {
+	lock, err := l.hold(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+
+	// the previous holder may have finished moments ago. Runs are recorded after the lock is taken, so this sees them.
+	// Only scheduled runs count, a manual run just before doesn't make the scheduled one skip
+	var recent bool
+	err = lock.conn.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM job_runs WHERE job = $1 AND trigger = $2 AND started_at > $3)",
+		job, TriggerSchedule, time.Now().Add(-lockSplay)).Scan(&recent)
+	if err != nil || recent {
+		lock.Unlock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Skipping job %s, another instance ran it less than %s ago", job, lockSplay)
+		return nil, ErrJobLocked
+	}
+
+	return lock, nil
+}
+
+// takes the lock of a job without looking at its recent runs
+func (l AdvisoryLocker) hold(ctx context.Context, job string) (*advisoryLock, error) {
+	key := advisoryLockKey("job/" + job)
+	conn, acquired, err := tryAdvisoryLock(ctx, l.pool, key)
+	if err != nil {
+		return nil, fmt.Errorf("locking job %s: %w", job, err)
+	}
+	if !acquired {
+		return nil, ErrJobLocked
+	}
+
+	return &advisoryLock{conn: conn, key: key}, nil
+}
+
+func (l *advisoryLock) Unlock(ctx context.Context) error {
+	return releaseAdvisoryLock(ctx, l.conn, l.key)
+}
+
+// takes the lock of a job for a run that gocron hasn't locked, so it can't overlap a run of the same job
+// by the scheduler, the admin api or the command line on any instance. With splay it's refused like a scheduled
+// run in lock mode, if the job started within lockSplay. Call the returned function to release it
+func lockJobRun(job string, splay bool) (func(), error) {
+	ctx, cancel := dbContext(context.Background())
+	defer cancel()
+
+	locker := AdvisoryLocker{pool: db}
+	var lock gocron.Lock
+	var err error
+	if splay {
+		lock, err = locker.Lock(ctx, job)
+	} else {
+		lock, err = locker.hold(ctx, job)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		ctx, cancel := dbContext(context.Background())
+		defer cancel()
+		err := lock.Unlock(ctx)
+		if err != nil {
+			log.Println("Error unlocking job:", err)
+		}
+	}, nil
+}
+
+// the elector of this instance in leader mode, so it can step down on shutdown
+var elector *AdvisoryElector
+
+// scheduler options for the configured coordination between replicas
+func coordinationOptions(mode string) []gocron.SchedulerOption {
+	switch mode {
+	case CoordinationLeader:
+		elector = newAdvisoryElector(db)
+		return []gocron.SchedulerOption{gocron.WithDistributedElector(elector)}
+	case CoordinationLock:
+		return []gocron.SchedulerOption{gocron.WithDistributedLocker(AdvisoryLocker{pool: db})}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestAdvisoryLockKey(t *testing.T) {
+	if advisoryLockKey("job/rss") != advisoryLockKey("job/rss") {
+		t.Error("keys must be the same on every replica")
+	}
+	seen := map[int64]string{advisoryLockKey("leader"): "leader"}
+	for _, job := range jobs {
+		key := advisoryLockKey("job/" + job.Name)
+		if other, ok := seen[key]; ok {
+			t.Errorf("%s and %s share a lock key", job.Name, other)
+		}
+		seen[key] = job.Name
+	}
+
+	if coordinationOptions(CoordinationNone) != nil {
+		t.Error("expected no distributed options for a single instance")
+	}
+	c := defaultConfig()
+	c.JobCoordination = "everyone"
+	if err := c.Validate(); err == nil {
+		t.Error("expected an error for an unknown coordination mode")
+	}
+}
@@ -5,26 +5,25 @@ import (
 	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
 	"math/rand"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
-	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/go-co-op/gocron/v2"
-	"github.com/go-echarts/go-echarts/v2/charts"
-	"github.com/go-echarts/go-echarts/v2/opts"
 	"github.com/gocolly/colly"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/generative-ai-go/genai"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/mmcdole/gofeed"
 	"google.golang.org/api/option"
@@ -85,11 +84,16 @@ type CoinData struct {
 }
 
 type CoinSentiment struct {
-	id        int
-	createdAt time.Time
-	sentiment int // -1: negative, 0: neutral, 1: positive
-	coin      string
-	source    string
+	id         int
+	createdAt  time.Time
+	sentiment  int // -1: negative, 0: neutral, 1: positive
+	coin       string
+	source     string
+	score      float64 // -1.0 to 1.0
+	confidence float64 // 0.0 to 1.0
+	rationale  string
+	title      string
+	publisher  string
 }
 
 // create struct for usb_conversions table
@@ -114,6 +118,22 @@ type GhostPost struct {
 	Featured     bool   `json:"featured"`
 	Status       string `json:"status"`
 	Visibility   string `json:"visibility"` // members, public
+	CanonicalURL string `json:"canonical_url,omitempty"`
+}
+
+// feed to pull articles from. Some publishers require the canonical url of the paraphrased post to point back at them
+type RSSFeed struct {
+	URL          string
+	UseCanonical bool
+}
+
+// details about the original article, used to credit the publisher at the end of a post
+type SourceAttribution struct {
+	URL         string
+	Title       string
+	Author      string
+	Publisher   string
+	Description string
 }
 
 type RandomUnSplashResponse struct {
@@ -458,217 +478,202 @@ type MarketForecast struct {
 	Description string
 	Currency    string
 	Price       float64
-	Chatter     []string
+	Chatter     []ChatterItem
 	OneWeek     float64
 	OneMonth    float64
 	ThreeMonths float64
-	Change24h   float64
+	Change24h   PercentChange
+	Change7d    PercentChange
+	Change30d   PercentChange
+	Charts      []template.HTML
+	Technicals  []IndicatorRow
+	Mood        template.HTML
 }
 
-var forecastTemplate = `
-<!DOCTYPE html>
-<html>
-<body>
-	<p>{{.Description}}</p>
-	<table border="1">
-		<tr>
-			<th>Currency</th>
-			<th>Price (USD)</th>
-			<th>Change 24h (%)</th>
-		</tr>
-		<tr>
-			<td>{{.Currency}}</td>
-			<td>{{.Price}}</td>
-			<td>{{.Change24h}}</td>
-		</tr>
-	</table>
-	<h2>Forecast</h2>
-	<ul>
-		<li>1 Week: {{.OneWeek}}</li>
-		<li>1 Month: {{.OneMonth}}</li>
-		<li>3 Months: {{.ThreeMonths}}</li>
-	</ul>
-	<h2>Chatter</h2>
-	<ul>
-		{{range .Chatter}}
-		<li>{{.}}</li>
-		{{end}}
-	</ul>
-</body>
-</html>
-`
+type NewsPost struct {
+	Content template.HTML
+	Source  SourceAttribution
+}
 
 var db *pgxpool.Pool
 
-func main() {
-	// load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
-
-	// connect to the database
-	db, err = pgxpool.New(context.Background(), os.Getenv("databaseUrl"))
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Unable to connect to database: %v\n", err)
-		os.Exit(1)
-	}
-	defer db.Close()
+// posts the weekly forecasts and returns how many were published
+func postPredictions(ctx context.Context) (int, error) {
+	posted := 0
+	var errs []error
+	for _, coin := range []string{"BTC", "ETH", "LTC"} {
+		if isDraining() {
+			return posted, ErrShuttingDown
+		}
 
-	// create a scheduler
-	s, err := gocron.NewScheduler()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// market watcher job
-	_, err = s.NewJob(
-		gocron.DurationJob(
-			30*time.Minute,
-		),
-		gocron.NewTask(
-			func() {
-				log.Println("Checking market values")
-				getAllCoinValues()
-			},
-		),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// predictions job
-	_, err = s.NewJob(
-		gocron.DailyJob(1, gocron.NewAtTimes(
-			gocron.NewAtTime(12, 0, 0),
-		)),
-		gocron.NewTask(
-			func() {
-				if time.Now().Weekday() == time.Sunday {
-					log.Println("Creating predictions")
-					postPredictions()
-				}
-			},
-		),
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// rss job
-	_, err = s.NewJob(
-		gocron.DurationJob(
-			60*time.Minute*4, // every 4 hours
-		),
-		gocron.NewTask(
-			func() {
-				log.Println("Checking rss")
-				checkFeedsAndPost()
-			},
-		),
-	)
-	if err != nil {
-		log.Fatal(err)
+		err := dailyForecast(ctx, coin)
+		if err != nil {
+			log.Println(err)
+			errs = append(errs, err)
+			continue
+		}
+		posted++
 	}
-	s.Start()
 
-	// create a web server to keep the program running and to provide a health check
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "OK")
-	})
-	http.ListenAndServe(":8080", nil)
+	return posted, errors.Join(errs...)
 }
 
-func postPredictions() {
-	dailyForecast("BTC")
-	dailyForecast("ETH")
-	dailyForecast("LTC")
+// feeds paraphrased by the rss job
+var rssFeeds = []RSSFeed{
+	{URL: "https://www.coindesk.com/feed", UseCanonical: true},
+	{URL: "https://cointelegraph.com/rss", UseCanonical: true},
+	{URL: "https://cryptopotato.com/feed"},
+	{URL: "https://cryptoslate.com/feed"},
+	{URL: "https://cryptonews.com/feed"},
+	{URL: "https://cryptobriefing.com/feed"},
+	{URL: "https://cryptocurrencynews.com/feed"},
+	{URL: "https://cryptoslate.com/feed"},
 }
 
-func checkFeedsAndPost() {
-	feeds := []string{
-		"https://www.coindesk.com/feed",
-		"https://cointelegraph.com/rss",
-		"https://cryptopotato.com/feed",
-		"https://cryptoslate.com/feed",
-		"https://cryptonews.com/feed",
-		"https://cryptobriefing.com/feed",
-		"https://cryptocurrencynews.com/feed",
-		"https://cryptoslate.com/feed",
-	}
+// paraphrases new articles from the feeds and returns how many were posted. Fails only if no feed could be read
+func checkFeedsAndPost(ctx context.Context) (int, error) {
+	feeds := slices.Clone(rssFeeds)
 
 	// randomize the order of the feeds
 	rand.Shuffle(len(feeds), func(i, j int) { feeds[i], feeds[j] = feeds[j], feeds[i] })
 
-	for _, feed := range feeds {
-		log.Println("Parsing feed: ", feed)
-
-		fp := gofeed.NewParser()
-		feed, err := fp.ParseURL(feed)
+	posted, failed := 0, 0
+	var lastErr error
+	for _, source := range feeds {
+		n, err := checkFeedAndPost(ctx, source)
+		posted += n
+		if errors.Is(err, ErrShuttingDown) || ctx.Err() != nil {
+			return posted, err
+		}
 		if err != nil {
 			log.Println(err)
-			continue
+			failed++
+			lastErr = err
 		}
+	}
 
-		for _, item := range feed.Items {
-			time.Sleep(30 * time.Second)
-			log.Println("Parsing article: ", item.Title)
+	if failed == len(feeds) {
+		return posted, fmt.Errorf("no feed could be read: %w", lastErr)
+	}
+	return posted, nil
+}
 
-			if isArticleAlreadyParaphrased(item.Link) {
-				log.Println("Article already paraphrased")
-				continue
-			}
+// paraphrases new articles from one feed and returns how many were posted
+func checkFeedAndPost(ctx context.Context, source RSSFeed) (int, error) {
+	log.Println("Parsing feed: ", source.URL)
 
-			text := getTextFromArticle(item.Link)
-			pContent, pTitle, err := paraphrase(text[0], item.Title)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			determineHeadlineSetiment(item.Title, "BTC", item.Link)
+	fp := gofeed.NewParser()
+	fp.Client = httpClient(config.Timeouts.Web)
+	feed, err := fp.ParseURLWithContext(source.URL, ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	posted := 0
+	for _, item := range feed.Items {
+		err = pause(ctx, 30*time.Second)
+		if err != nil {
+			return posted, err
+		}
+		log.Println("Parsing article: ", item.Title)
+
+		if isArticleAlreadyParaphrased(ctx, item.Link) {
+			log.Println("Article already paraphrased")
+			continue
+		}
 
-			standardPost(pContent, pTitle, item.Link)
+		err = paraphraseAndPost(ctx, attributionFromItem(feed, item), source.UseCanonical)
+		if err != nil {
+			log.Println(err)
+			continue
 		}
+		posted++
 	}
+
+	return posted, nil
 }
 
-func getAllCoinValues() {
-	getCoinValue("BTC")
-	time.Sleep(10 * time.Second)
+// paraphrases a single article, whether or not it's in one of the feeds
+func postURL(ctx context.Context, link string) error {
+	if isArticleAlreadyParaphrased(ctx, link) {
+		return fmt.Errorf("article already paraphrased: %s", link)
+	}
 
-	getCoinValue("ETH")
-	time.Sleep(10 * time.Second)
+	// articles from publishers that allow it keep pointing search engines at the original, as they do from the feeds
+	canonical := false
+	if u, err := url.Parse(link); err == nil {
+		for _, feed := range rssFeeds {
+			if f, err := url.Parse(feed.URL); err == nil && f.Hostname() == u.Hostname() {
+				canonical = feed.UseCanonical
+			}
+		}
+	}
 
-	getCoinValue("LTC")
-	time.Sleep(10 * time.Second)
+	return paraphraseAndPost(ctx, attributionFromURL(ctx, link), canonical)
+}
 
-	getCoinValue("DOGE")
-	time.Sleep(10 * time.Second)
+// paraphrases the article, records the sentiment of its headline and posts it with credit to the source
+func paraphraseAndPost(ctx context.Context, source SourceAttribution, canonical bool) error {
+	text := getTextFromArticle(ctx, source.URL)
+	if len(text) == 0 {
+		return fmt.Errorf("no text found in %s", source.URL)
+	}
 
-	getCoinValue("SHIB")
-	time.Sleep(10 * time.Second)
+	pContent, pTitle, err := paraphrase(ctx, text[0], source.Title)
+	if err != nil {
+		return err
+	}
+	for _, coin := range detectCoins(ctx, source.Title, text[0]) {
+		determineHeadlineSetiment(ctx, source, coin)
+	}
+	markArticleParaphrased(ctx, source.URL)
 
-	getCoinValue("LINK")
-	time.Sleep(10 * time.Second)
+	return standardPost(ctx, pContent, pTitle, source, canonical)
+}
 
-	getCoinValue("XMR")
-	time.Sleep(10 * time.Second)
+// coins tracked by the market watcher
+var watchlist = []string{"BTC", "ETH", "LTC", "DOGE", "SHIB", "LINK", "XMR", "SOL", "USDT", "XTZ"}
 
-	getCoinValue("SOL")
-	time.Sleep(10 * time.Second)
+// stores a sample of every coin on the watchlist and returns how many were fetched. Every run is kept,
+// so changes over short windows such as an hour have samples at both ends.
+// The whole watchlist is one request, which costs a single api credit
+func getAllCoinValues(ctx context.Context) (int, error) {
+	values, err := getCoinValuesFromAPI(ctx, strings.Join(watchlist, ","))
+	if err == nil && values.Status.ErrorCode != 0 {
+		err = fmt.Errorf("coinmarketcap error %d: %s", values.Status.ErrorCode, values.Status.ErrorMessage)
+	}
+	if err != nil {
+		return 0, err
+	}
 
-	getCoinValue("USDT")
-	time.Sleep(10 * time.Second)
+	samples := []CoinConversion{}
+	for _, coin := range watchlist {
+		quotes := values.Data[coin]
+		if len(quotes) == 0 {
+			log.Println("No quote for", coin)
+			continue
+		}
+		samples = append(samples, CoinConversion{value: quotes[0].Quote.USD.Price, coin: coin})
+	}
+	saveCoinValuesToPostgres(ctx, samples)
+
+	updateRecentCandles(ctx, watchlist)
+	checkPriceAlerts(ctx, watchlist)
+	recordSentimentIndexes(ctx, watchlist)
 
-	getCoinValue("XTZ")
-	time.Sleep(10 * time.Second)
+	if len(samples) == 0 {
+		return 0, errors.New("no coin values could be fetched")
+	}
+	return len(samples), nil
 }
 
 var disclaimer = "This is not financial advice. This is for entertainment purposes only. Do your own research before making any investment. The author is not responsible for any losses incurred. The information on this page is simply opinion based on publicly available data"
 
-func isArticleAlreadyParaphrased(url string) bool {
+func isArticleAlreadyParaphrased(ctx context.Context, url string) bool {
 	// check if the article is already in the database, table rss_posts
-	rows, err := db.Query(context.Background(), "SELECT * FROM rss_posts WHERE url = $1", url)
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT * FROM rss_posts WHERE url = $1", url)
 	if err != nil {
 		log.Printf("Error querying database: %v", err)
 	}
@@ -681,101 +686,246 @@ func isArticleAlreadyParaphrased(url string) bool {
 	return false
 }
 
-func standardPost(content string, title string, source string) {
-	createPost(GhostPost{
+// save source to rss_posts (id, created_at, url) so it isn't paraphrased again
+func markArticleParaphrased(ctx context.Context, url string) {
+	if dryRunFrom(ctx) != nil {
+		return
+	}
+
+	_, err := dbExec(ctx, "INSERT INTO rss_posts (url) VALUES ($1)", url)
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func standardPost(ctx context.Context, content string, title string, source SourceAttribution, canonical bool) error {
+	html, err := templates.Render("news.html", NewsPost{
+		Content: template.HTML(content),
+		Source:  bookmarkSource(source),
+	})
+	if err != nil {
+		return fmt.Errorf("executing template: %w", err)
+	}
+
+	post := GhostPost{
 		Title:        title,
-		HTML:         content + "<br><br><a href='" + source + "'>Source</a>",
-		FeatureImage: fetchUnsplashImage("cryptocurrency").Urls.Small,
+		HTML:         html,
+		FeatureImage: fetchUnsplashImage(ctx, "cryptocurrency").Urls.Small,
 		Featured:     false,
 		Status:       "published",
 		Visibility:   "public",
+	}
+	if canonical {
+		post.CanonicalURL = source.URL
+	}
+
+	return createPost(ctx, post)
+}
+
+// collect the publisher, title and author of a feed item for attribution
+func attributionFromItem(feed *gofeed.Feed, item *gofeed.Item) SourceAttribution {
+	source := SourceAttribution{
+		URL:         item.Link,
+		Title:       item.Title,
+		Publisher:   feed.Title,
+		Description: item.Description,
+	}
+
+	if item.Author != nil {
+		source.Author = item.Author.Name
+	} else if len(item.Authors) > 0 && item.Authors[0] != nil {
+		source.Author = item.Authors[0].Name
+	}
+
+	// descriptions are often html, keep only a short plain text summary
+	if len(source.Description) > 200 || strings.Contains(source.Description, "<") {
+		source.Description = ""
+	}
+
+	if source.Publisher == "" {
+		if u, err := url.Parse(item.Link); err == nil {
+			source.Publisher = u.Hostname()
+		}
+	}
+
+	return source
+}
+
+// collect the title, publisher and author of an article from its metadata, for urls that aren't from a feed
+func attributionFromURL(ctx context.Context, link string) SourceAttribution {
+	source := SourceAttribution{URL: link}
+
+	c := newCollector(ctx)
+	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36"
+	c.IgnoreRobotsTxt = true
+	c.CacheDir = "./cache"
+
+	c.OnHTML("head", func(e *colly.HTMLElement) {
+		source.Title = e.ChildAttr(`meta[property="og:title"]`, "content")
+		if source.Title == "" {
+			source.Title = strings.TrimSpace(e.ChildText("title"))
+		}
+		source.Publisher = e.ChildAttr(`meta[property="og:site_name"]`, "content")
+		source.Author = e.ChildAttr(`meta[name="author"]`, "content")
+		source.Description = e.ChildAttr(`meta[property="og:description"]`, "content")
+	})
+
+	c.OnError(func(_ *colly.Response, err error) {
+		log.Println("Something went wrong:", err)
 	})
+
+	c.Visit(link)
+
+	if len(source.Description) > 200 {
+		source.Description = ""
+	}
+	if source.Publisher == "" {
+		if u, err := url.Parse(link); err == nil {
+			source.Publisher = u.Hostname()
+		}
+	}
+
+	return source
+}
+
+// fills in what the bookmark card needs to show
+func bookmarkSource(source SourceAttribution) SourceAttribution {
+	if source.Title == "" {
+		source.Title = source.URL
+	}
+	return source
 }
 
-func dailyForecast(coin string) {
-	curr := getCoinValue(coin)
-	week, _ := forecast(coin, "1 week")
+func dailyForecast(ctx context.Context, coin string) error {
+	curr := getCoinValue(ctx, coin)
+	technicals, err := technicalSummary(ctx, coin)
+	if err != nil {
+		log.Println(err)
+	}
+	sentiment, err := computeSentimentIndex(ctx, coin)
+	if err != nil {
+		log.Println(err)
+	}
+
+	week, _ := forecast(ctx, coin, "1 week", technicals, sentiment)
 	weekF, _ := strconv.ParseFloat(week, 64)
-	month, _ := forecast(coin, "1 month")
+	month, _ := forecast(ctx, coin, "1 month", technicals, sentiment)
 	monthF, _ := strconv.ParseFloat(month, 64)
-	threeMonths, _ := forecast(coin, "3 months")
+	threeMonths, _ := forecast(ctx, coin, "3 months", technicals, sentiment)
 	threeMonthsF, _ := strconv.ParseFloat(threeMonths, 64)
-	//desc := generateForecastDescription(coin, curr, week, month, threeMonths)
+	//desc := generateForecastDescription(ctx, coin, curr, week, month, threeMonths)
 
 	// if any of the forecasts are 0, do not post
 	if weekF == 0 || monthF == 0 || threeMonthsF == 0 {
-		return
+		return fmt.Errorf("forecast of %s is incomplete: week %q, month %q, 3 months %q", coin, week, month, threeMonths)
 	}
 
 	forecastData := MarketForecast{
 		Description: disclaimer,
 		Currency:    coin,
 		Price:       curr,
-		Chatter:     sentimentUrlList(),
+		Chatter:     getCoinHeadlines(ctx, coin, 10, true),
 		OneWeek:     weekF,
 		OneMonth:    monthF,
 		ThreeMonths: threeMonthsF,
-		Change24h:   getPercentChange24h(coin),
+		Change24h:   publishedChange(ctx, coin, Window24h),
+		Change7d:    publishedChange(ctx, coin, Window7d),
+		Change30d:   publishedChange(ctx, coin, Window30d),
+		Technicals:  technicals.Rows(),
+		Mood:        moodWidget(currentCryptoMood(ctx)),
 	}
 
-	tmpl, err := template.New("forecast").Parse(forecastTemplate)
-	if err != nil {
-		fmt.Println("Error parsing template:", err)
-		return
+	// the past month with the forecasts appended, the past week and day, daily candles and the headline sentiment over the month
+	now := time.Now()
+	forecasts := []ForecastPoint{
+		{At: now.AddDate(0, 0, 7), Value: weekF},
+		{At: now.AddDate(0, 1, 0), Value: monthF},
+		{At: now.AddDate(0, 3, 0), Value: threeMonthsF},
+	}
+	featureImage := forecastCharts(ctx, &forecastData, coin, forecasts)
+	if featureImage == "" {
+		featureImage = fetchUnsplashImage(ctx, "cryptocurrency").Urls.Small
 	}
 
-	// write to string
-	var tpl bytes.Buffer
-	err = tmpl.Execute(&tpl, forecastData)
+	html, err := templates.Render("forecast.html", forecastData)
 	if err != nil {
-		fmt.Println("Error executing template:", err)
-		return
+		return fmt.Errorf("executing template: %w", err)
 	}
 
-	createPost(GhostPost{
+	return createPost(ctx, GhostPost{
 		Title:        "Weekly " + coin,
-		HTML:         tpl.String(),
-		FeatureImage: fetchUnsplashImage("cryptocurrency").Urls.Small,
+		HTML:         html,
+		FeatureImage: featureImage,
 		Featured:     true,
 		Status:       "published",
 		Visibility:   "public",
 	})
 }
 
-func getPercentChange24h(c string) float64 {
-	// get the 24h percent change of a cryptocurrency from a Postgres database only. Do not use API
-	change := 0.0
+// adds charts to the forecast and returns the url of the first price chart image, if one was uploaded.
+// Static images are preferred since newsletters can't run javascript, the interactive chart is a fallback
+func forecastCharts(ctx context.Context, forecastData *MarketForecast, coin string, forecasts []ForecastPoint) string {
+	featureImage := ""
+	name := strings.ToLower(coin) + "-" + time.Now().Format("2006-01-02")
+
+	for _, r := range forecastChartRanges {
+		// the forecasts reach months out, which would squash the shorter histories
+		points := forecasts
+		if r.Duration < Range30d.Duration {
+			points = nil
+		}
 
-	// get the values from the database
-	rows, err := db.Query(context.Background(), "SELECT * FROM exchange_rates WHERE coin = $1 ORDER BY created_at DESC LIMIT 2", c)
-	if err != nil {
-		log.Printf("Error querying database: %v", err)
-		return change
+		price, err := priceStaticChart(ctx, coin, r, points)
+		if err == nil {
+			var card template.HTML
+			var url string
+			card, url, err = chartImageCard(ctx, price, name+"-price-"+r.Name)
+			if err == nil {
+				forecastData.Charts = append(forecastData.Charts, card)
+				if featureImage == "" {
+					featureImage = url
+				}
+			}
+		}
+		if err != nil {
+			log.Println(err)
+			if chart, err := createPriceChart(ctx, coin, r, points); err == nil {
+				forecastData.Charts = append(forecastData.Charts, chart)
+			}
+		}
 	}
-	defer rows.Close()
 
-	var values []CoinConversion
-	for rows.Next() {
-		var value CoinConversion
-		err = rows.Scan(&value.createdAt, &value.coin, &value.value)
-		if err != nil {
-			log.Printf("Error scanning row: %v", err)
+	candles, err := candleStaticChart(ctx, coin, Interval1d, Range30d)
+	if err == nil {
+		var card template.HTML
+		card, _, err = chartImageCard(ctx, candles, name+"-candles")
+		if err == nil {
+			forecastData.Charts = append(forecastData.Charts, card)
 		}
-		values = append(values, value)
+	}
+	if err != nil {
+		log.Println(err)
 	}
 
-	if len(values) == 2 {
-		change = (values[0].value - values[1].value) / values[1].value
-		change = change * 100
+	sentiment, err := sentimentStaticChart(ctx, coin, Range30d)
+	if err == nil {
+		var card template.HTML
+		card, _, err = chartImageCard(ctx, sentiment, name+"-sentiment")
+		if err == nil {
+			forecastData.Charts = append(forecastData.Charts, card)
+		}
+	}
+	if err != nil {
+		log.Println(err)
 	}
 
-	return change
+	return featureImage
 }
 
-func getCoinValue(c string) float64 {
+func getCoinValue(ctx context.Context, c string) float64 {
 	// get the value of a cryptocurrency from a Postgres database. If the value is not found, or is older than 4 hours, get it from an API.
 	// If the API is down, return the most recent value from the database.
-	localVal, err := getValueFromPostgres(c)
+	localVal, err := getValueFromPostgres(ctx, c)
 	if err != nil {
 		log.Println(err)
 		return 0
@@ -784,12 +934,12 @@ func getCoinValue(c string) float64 {
 		log.Println("Value is older than a 4 hours, getting from API -" + c)
 
 		// get the value from the API
-		values, err := getCoinValuesFromAPI(c)
+		values, err := getCoinValuesFromAPI(ctx, c)
 		if err != nil {
 			log.Println(err)
 			return localVal.value
 		}
-		saveCoinValuesToPostgres([]CoinConversion{
+		saveCoinValuesToPostgres(ctx, []CoinConversion{
 			{
 				value: values.Data[c][0].Quote.USD.Price,
 				coin:  c,
@@ -803,13 +953,15 @@ func getCoinValue(c string) float64 {
 }
 
 // wrapper function to prioritize getting values from Postgres database
-func getCoinValuesTimeRange(start int64, coin string) ([]CoinConversion, error) {
+func getCoinValuesTimeRange(ctx context.Context, start int64, coin string) ([]CoinConversion, error) {
 	// the API does not provide historical data, so we must rely on the database for this information.
 	// get all values from the database from start to now
 	values := []CoinConversion{}
 
 	// get the values from the database
-	rows, err := db.Query(context.Background(), "SELECT * FROM exchange_rates WHERE coin = $1 AND created_at > $2 ORDER BY created_at DESC", coin, time.Unix(start, 0))
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT * FROM exchange_rates WHERE coin = $1 AND created_at > $2 ORDER BY created_at DESC", coin, time.Unix(start, 0))
 	if err != nil {
 		log.Printf("Error querying database: %v", err)
 		return values, err
@@ -830,8 +982,10 @@ func getCoinValuesTimeRange(start int64, coin string) ([]CoinConversion, error)
 }
 
 // get the value of a cryptocurrency from a Postgres database
-func getValueFromPostgres(c string) (CoinConversion, error) {
-	rows, err := db.Query(context.Background(), "SELECT * FROM exchange_rates WHERE coin = $1 ORDER BY created_at DESC LIMIT 1", c)
+func getValueFromPostgres(ctx context.Context, c string) (CoinConversion, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT * FROM exchange_rates WHERE coin = $1 ORDER BY created_at DESC LIMIT 1", c)
 	if err != nil {
 		log.Printf("Error querying database: %v", err)
 		return CoinConversion{}, err
@@ -850,33 +1004,11 @@ func getValueFromPostgres(c string) (CoinConversion, error) {
 	return value, nil
 }
 
-// add up all the sentiment values for the coin from the past h hours
-func getCoinSentiment(c string, h int) int {
-	rows, err := db.Query(context.Background(), "SELECT * FROM sentiments WHERE coin = ? AND created_at > ? ORDER BY created_at DESC", c, time.Now().Add(-1*time.Hour*time.Duration(h)))
-	if err != nil {
-		log.Printf("Error querying database: %v", err)
-		return 0
-	}
-	defer rows.Close()
-
-	var value int
-	for rows.Next() {
-		var sentiment CoinSentiment
-		err = rows.Scan(&sentiment.id, &sentiment.createdAt, &sentiment.coin, &sentiment.sentiment, &sentiment.source)
-		if err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
-		}
-		value += sentiment.sentiment
-	}
-
-	return value
-}
-
 // save the sentiment of a cryptocurrency to a Postgres database
-func saveCoinSentimentToPostgres(sentiments []CoinSentiment) {
+func saveCoinSentimentToPostgres(ctx context.Context, sentiments []CoinSentiment) {
 	for _, sentiment := range sentiments {
-		_, err := db.Exec(context.Background(), "INSERT INTO sentiments (sentiment, coin, source) VALUES ($1, $2, $3)", sentiment.sentiment, sentiment.coin, sentiment.source)
+		_, err := dbExec(ctx, "INSERT INTO sentiments (sentiment, coin, source, score, confidence, rationale, title, publisher) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			sentiment.sentiment, sentiment.coin, sentiment.source, sentiment.score, sentiment.confidence, sentiment.rationale, sentiment.title, sentiment.publisher)
 		if err != nil {
 			log.Println(err)
 		}
@@ -884,9 +1016,9 @@ func saveCoinSentimentToPostgres(sentiments []CoinSentiment) {
 }
 
 // save the value of a cryptocurrency to a Postgres database
-func saveCoinValuesToPostgres(values []CoinConversion) {
+func saveCoinValuesToPostgres(ctx context.Context, values []CoinConversion) {
 	for _, value := range values {
-		_, err := db.Exec(context.Background(), "INSERT INTO exchange_rates (value, coin) VALUES ($1, $2)", value.value, value.coin)
+		_, err := dbExec(ctx, "INSERT INTO exchange_rates (value, coin) VALUES ($1, $2)", value.value, value.coin)
 		if err != nil {
 			log.Println(err)
 		}
@@ -894,11 +1026,11 @@ func saveCoinValuesToPostgres(values []CoinConversion) {
 }
 
 // get the value of a cryptocurrency from an API. coins is a comma-separated list of coin symbols
-func getCoinValuesFromAPI(coin string) (CoinValuesResponse, error) {
+func getCoinValuesFromAPI(ctx context.Context, coin string) (CoinValuesResponse, error) {
 	log.Println("Getting coin values from API")
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", "https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest", nil)
+	client := httpClient(config.Timeouts.CoinMarket)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest", nil)
 	if err != nil {
 		log.Print(err)
 		return CoinValuesResponse{}, err
@@ -908,7 +1040,7 @@ func getCoinValuesFromAPI(coin string) (CoinValuesResponse, error) {
 	q.Add("symbol", coin)
 
 	req.Header.Set("Accepts", "application/json")
-	req.Header.Add("X-CMC_PRO_API_KEY", os.Getenv("coinmarketKey"))
+	req.Header.Add("X-CMC_PRO_API_KEY", config.CoinmarketKey)
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := client.Do(req)
@@ -916,6 +1048,7 @@ func getCoinValuesFromAPI(coin string) (CoinValuesResponse, error) {
 		log.Println("Error sending request to server")
 		return CoinValuesResponse{}, err
 	}
+	defer resp.Body.Close()
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Println("Error reading response body")
@@ -934,10 +1067,10 @@ func getCoinValuesFromAPI(coin string) (CoinValuesResponse, error) {
 }
 
 // scrape body text from articles using colly v2
-func getTextFromArticle(url string) []string {
+func getTextFromArticle(ctx context.Context, url string) []string {
 	text := []string{}
 
-	c := colly.NewCollector()
+	c := newCollector(ctx)
 	c.UserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 Safari/537.36"
 	c.Limit(&colly.LimitRule{
 		Delay: 5 * time.Second,
@@ -967,22 +1100,28 @@ func getTextFromArticle(url string) []string {
 	return text
 }
 
-func fetchUnsplashImage(query string) RandomUnSplashResponse {
+func fetchUnsplashImage(ctx context.Context, query string) RandomUnSplashResponse {
 	// replace spaces with %20
 	query = strings.ReplaceAll(query, " ", "%20")
-	token := os.Getenv("unsplashBearer")
+	token := config.UnsplashBearer
+	if token == "" {
+		// no feature image rather than a failed request
+		return RandomUnSplashResponse{}
+	}
 	url := "https://api.unsplash.com/photos/random?client_id=583846&query=" + query
 	method := "GET"
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+	client := httpClient(config.Timeouts.Unsplash)
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		log.Println(err)
+		return RandomUnSplashResponse{}
 	}
 	req.Header.Add("Authorization", "Bearer "+token)
 
 	res, err := client.Do(req)
 	if err != nil {
 		log.Println(err)
+		return RandomUnSplashResponse{}
 	}
 	defer res.Body.Close()
 
@@ -1001,8 +1140,8 @@ func fetchUnsplashImage(query string) RandomUnSplashResponse {
 }
 
 func generateJwt() string {
-	secret := os.Getenv("adminKey")
-	id := os.Getenv("adminId")
+	secret := config.AdminKey
+	id := config.AdminID
 
 	// Decode hexadecimal secret into original binary byte array
 	secretBytes, err := hex.DecodeString(secret)
@@ -1031,85 +1170,119 @@ func generateJwt() string {
 	return tokenString
 }
 
-func generateBarItems() []opts.BarData {
-	items := make([]opts.BarData, 0)
-	for i := 0; i < 7; i++ {
-		items = append(items, opts.BarData{Value: rand.Intn(300)})
+func createPost(ctx context.Context, content GhostPost) error {
+	if d := dryRunFrom(ctx); d != nil {
+		return d.recordPost(content)
 	}
-	return items
-}
 
-func createLineChart() string {
-	// returns html of a line chart
-	// create a new bar instance
-	bar := charts.NewBar()
-	// set some global options like Title/Legend/ToolTip or anything else
-	bar.SetGlobalOptions(charts.WithTitleOpts(opts.Title{
-		Title:    "My first bar chart generated by go-echarts",
-		Subtitle: "It's extremely easy to use, right?",
-	}))
-
-	// Put data into instance
-	bar.SetXAxis([]string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"}).
-		AddSeries("Category A", generateBarItems()).
-		AddSeries("Category B", generateBarItems())
-
-	// create writer to write to a string
-	f := &strings.Builder{}
-
-	bar.Render(f)
-
-	htmlString := f.String()
-	htmlString = "<!--kg-card-begin: html-->" + htmlString
-	htmlString = htmlString + "<!--kg-card-end: html-->"
-
-	return htmlString
-}
-
-func createPost(content GhostPost) {
 	posts := GhostPosts{
 		Posts: []GhostPost{
 			content,
 		},
 	}
 
-	url := "https://cryptobunker.org/ghost/api/admin/posts/?source=html"
+	url := config.GhostAdminURL + "posts/?source=html"
 	method := "POST"
 
 	json, err := json.Marshal(posts)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 	payload := strings.NewReader(string(json))
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
+	client := httpClient(config.Timeouts.Ghost)
+	req, err := http.NewRequestWithContext(ctx, method, url, payload)
 
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 	req.Header.Add("Authorization", "Ghost "+generateJwt())
 	req.Header.Add("Content-Type", "application/json")
 
 	res, err := client.Do(req)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return fmt.Errorf("creating post %q: unexpected status %s", content.Title, res.Status)
+	}
+
+	return nil
+}
+
+// upload an image to ghost and return its url
+func uploadGhostImage(ctx context.Context, name string, data []byte, contentType string) (string, error) {
+	if d := dryRunFrom(ctx); d != nil {
+		return d.recordImage(name, data)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", err
+	}
+	_, err = part.Write(data)
+	if err != nil {
+		return "", err
+	}
+	writer.WriteField("purpose", "image")
+	writer.WriteField("ref", name)
+	err = writer.Close()
+	if err != nil {
+		return "", err
+	}
+
+	client := httpClient(config.Timeouts.Ghost)
+	req, err := http.NewRequestWithContext(ctx, "POST", config.GhostAdminURL+"images/upload/", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Authorization", "Ghost "+generateJwt())
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
 	}
 	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("uploading image %s: unexpected status %s", name, res.Status)
+	}
+
+	var uploaded struct {
+		Images []struct {
+			URL string `json:"url"`
+		} `json:"images"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&uploaded)
+	if err != nil {
+		return "", err
+	}
+	if len(uploaded.Images) == 0 {
+		return "", fmt.Errorf("uploading image %s: no image in response", name)
+	}
+
+	return uploaded.Images[0].URL, nil
 }
 
-func paraphrase(text string, source string) (string, string, error) {
+func paraphrase(ctx context.Context, text string, source string) (string, string, error) {
 	// ensure text is less than 2048 characters
 	if len(text) > 2048 {
 		text = text[:2048]
 	}
 
-	ctx := context.Background()
+	ctx, cancel := llmContext(ctx)
+	defer cancel()
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("geminiKey")))
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GeminiKey))
 	if err != nil {
 		log.Println(err)
 	}
@@ -1136,70 +1309,98 @@ func paraphrase(text string, source string) (string, string, error) {
 	return body, title, nil
 }
 
-func determineHeadlineSetiment(text string, coin string, source string) (int, error) {
-	ctx := context.Background()
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("geminiKey")))
-	if err != nil {
-		log.Println(err)
-	}
-	defer client.Close()
+func determineHeadlineSetiment(ctx context.Context, source SourceAttribution, coin string) (HeadlineSentiment, error) {
+	text := source.Title
+	var parsed HeadlineSentiment
+	var err error
 
-	model := client.GenerativeModel("gemini-pro")
-	resp, err := model.GenerateContent(ctx, genai.Text("return -1 if the following headline could have a negative impact on the market value of "+coin+", 1 for positive, 0 for no effect at all: "+text))
+	if sentimentBudget.allow(sentimentLLMDailyBudget) {
+		parsed, err = llmHeadlineSentiment(ctx, text, coin)
+		if err == nil {
+			crossCheckSentiment(text, coin, parsed)
+		}
+	} else {
+		err = errors.New("daily llm sentiment budget spent")
+	}
 	if err != nil {
-		log.Println(err)
-		return 0, err
+		log.Println(err, "- falling back to lexicon sentiment")
+		parsed = lexiconSentiment(text)
 	}
 
-	parsed, err := strconv.Atoi(fmt.Sprint(resp.Candidates[0].Content.Parts[0]))
-	if err != nil {
-		log.Println(err)
+	if dryRunFrom(ctx) != nil {
+		return parsed, nil
 	}
 
 	// save the sentiment to the database
-	saveCoinSentimentToPostgres([]CoinSentiment{
+	saveCoinSentimentToPostgres(ctx, []CoinSentiment{
 		{
-			createdAt: time.Now(),
-			sentiment: parsed,
-			coin:      coin,
-			source:    source,
+			createdAt:  time.Now(),
+			sentiment:  parsed.Direction(),
+			coin:       coin,
+			source:     source.URL,
+			score:      parsed.Score,
+			confidence: parsed.Confidence,
+			rationale:  parsed.Rationale,
+			title:      source.Title,
+			publisher:  source.Publisher,
 		},
 	})
 
-	// save source to rss_posts (id, created_at, url)
-	_, err = db.Exec(ctx, "INSERT INTO rss_posts (url) VALUES ($1)", source)
+	return parsed, nil
+}
+
+func llmHeadlineSentiment(ctx context.Context, text string, coin string) (HeadlineSentiment, error) {
+	ctx, cancel := llmContext(ctx)
+	defer cancel()
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GeminiKey))
 	if err != nil {
-		log.Println(err)
+		return HeadlineSentiment{}, err
 	}
+	defer client.Close()
 
-	return parsed, nil
+	model := client.GenerativeModel("gemini-pro")
+	model.SetTemperature(0)
+	resp, err := model.GenerateContent(ctx, genai.Text(headlineSentimentPrompt(text, coin)))
+	if err != nil {
+		return HeadlineSentiment{}, err
+	}
+
+	return parseHeadlineSentiment(fmt.Sprint(resp.Candidates[0].Content.Parts[0]))
+}
+
+// prices for a prompt with their significant digits, so SHIB isn't sent as a list of zeros.
+// Separated by semicolons, since the prices have thousands separators
+func promptPrices(values []CoinConversion) string {
+	prices := make([]string, 0, len(values))
+	for _, value := range values {
+		prices = append(prices, promptFormatter.Price(value.value))
+	}
+	return strings.Join(prices, "; ")
 }
 
-func forecast(coin string, timespan string) (string, error) {
-	values := []int{}
+// how much price history the llm is given to forecast from
+var forecastHistory = 1000 * time.Hour
 
-	from := time.Now().Add(-1 * time.Hour * time.Duration(1000)).Unix()
+func forecast(ctx context.Context, coin string, timespan string, technicals TechnicalSummary, sentiment SentimentIndex) (string, error) {
+	from := time.Now().Add(-forecastHistory).Unix()
 
 	// get the values of the coin from the past h hours
-	coinValues, err := getCoinValuesTimeRange(from, coin)
+	coinValues, err := getCoinValuesTimeRange(ctx, from, coin)
 	if err != nil {
 		return "", err
 	}
 
-	for _, value := range coinValues {
-		values = append(values, int(value.value))
-	}
-
-	ctx := context.Background()
+	ctx, cancel := llmContext(ctx)
+	defer cancel()
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("geminiKey")))
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GeminiKey))
 	if err != nil {
 		return "", err
 	}
 	defer client.Close()
 
 	model := client.GenerativeModel("gemini-pro")
-	resp, err := model.GenerateContent(ctx, genai.Text("You are a finicial consultant. It is required you give a best guess. Only provide a USD estimate and nothing else. Do not use special characters. just numbers. Forecast the price of "+coin+" "+timespan+" from now. Here is a list of recent values in USD from the last 168 hours "+fmt.Sprint(values)))
+	resp, err := model.GenerateContent(ctx, genai.Text("You are a finicial consultant. It is required you give a best guess. Only provide a USD estimate and nothing else. Do not use special characters. just numbers. Forecast the price of "+coin+" "+timespan+" from now. Here is a list of recent values in USD from the last "+fmt.Sprint(forecastHistory.Hours())+" hours, newest first: "+promptPrices(coinValues)+". "+technicals.Prompt()+" "+sentiment.Prompt()))
 	if err != nil {
 		return "", err
 	}
@@ -1207,14 +1408,15 @@ func forecast(coin string, timespan string) (string, error) {
 	return fmt.Sprint(resp.Candidates[0].Content.Parts[0]), nil
 }
 
-func generateForecastDescription(coin string, current float64, week float64, month float64, months float64) string {
+func generateForecastDescription(ctx context.Context, coin string, current float64, week float64, month float64, months float64) string {
 	// generate a description of the forecast
 	prompt := "Speak objectively and do not speak in the first person. Return plain text without markdown or html, do not stylize. Based on the forecasted values of " + coin + " over the next week, month, and 3 months, provide a summary of the forecast." +
-		"Current value: " + fmt.Sprint(current) + ", 1 week: " + fmt.Sprint(week) + ", 1 month: " + fmt.Sprint(month) + ", 3 months: " + fmt.Sprint(months)
+		"Current value: " + promptFormatter.Price(current) + ", 1 week: " + promptFormatter.Price(week) + ", 1 month: " + promptFormatter.Price(month) + ", 3 months: " + promptFormatter.Price(months)
 
-	ctx := context.Background()
+	ctx, cancel := llmContext(ctx)
+	defer cancel()
 
-	client, err := genai.NewClient(ctx, option.WithAPIKey(os.Getenv("geminiKey")))
+	client, err := genai.NewClient(ctx, option.WithAPIKey(config.GeminiKey))
 	if err != nil {
 		log.Println(err)
 	}
@@ -1228,26 +1430,3 @@ func generateForecastDescription(coin string, current float64, week float64, mon
 
 	return fmt.Sprint(resp.Candidates[0].Content.Parts[0])
 }
-
-// returns a string of html bullet points with sentiment analysis of recent news articles. Use the 10 most recent articles in the database. Does not use neutral sentiment.
-func sentimentUrlList() []string {
-	rows, err := db.Query(context.Background(), "SELECT * FROM sentiments WHERE sentiment <> 0 ORDER BY created_at DESC LIMIT 10")
-	if err != nil {
-		log.Printf("Error querying database: %v", err)
-		return []string{}
-	}
-	defer rows.Close()
-
-	var sentimentsUrls []string
-	for rows.Next() {
-		var sentiment CoinSentiment
-		err = rows.Scan(&sentiment.id, &sentiment.createdAt, &sentiment.coin, &sentiment.sentiment, &sentiment.source)
-		if err != nil {
-			log.Printf("Error scanning row: %v", err)
-			continue
-		}
-		sentimentsUrls = append(sentimentsUrls, sentiment.source)
-	}
-
-	return sentimentsUrls
-}
@@ -1,15 +1,51 @@
 package main
 
 import (
+	"context"
+	"html/template"
+	"os"
+	"strings"
 	"testing"
 )
 
+func TestMain(m *testing.M) {
+	var err error
+	templates, err = loadTemplates("templates")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
 // test functions to make the program handles intentionally bad parameters correctly
 func TestBadParameters(t *testing.T) {
-	getCoinValuesTimeRange(0, "")
-	getCoinValuesTimeRange(1, "")
-	getCoinValuesTimeRange(1, "bad")
+	ctx := context.Background()
+	getCoinValuesTimeRange(ctx, 0, "")
+	getCoinValuesTimeRange(ctx, 1, "")
+	getCoinValuesTimeRange(ctx, 1, "bad")
+
+	getValueFromPostgres(ctx, "")
+	getValueFromPostgres(ctx, "bad")
+}
+
+func TestBookmarkCard(t *testing.T) {
+	html, err := templates.Render("news.html", NewsPost{
+		Content: template.HTML("<p>Paraphrased</p>"),
+		Source: bookmarkSource(SourceAttribution{
+			URL:       "https://example.com/article",
+			Title:     "Bitcoin <rallies>",
+			Author:    "Jane Doe",
+			Publisher: "Example News",
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	getValueFromPostgres("")
-	getValueFromPostgres("bad")
+	for _, want := range []string{"<p>Paraphrased</p>", "kg-bookmark-card", "https://example.com/article", "Bitcoin &lt;rallies&gt;", "Jane Doe", "Example News"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("bookmark card missing %q: %s", want, html)
+		}
+	}
 }
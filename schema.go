@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// schema changes applied in order on startup. Append new statements, never edit or reorder applied ones
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS candles (
+		coin TEXT NOT NULL,
+		interval TEXT NOT NULL,
+		open_time TIMESTAMPTZ NOT NULL,
+		open DOUBLE PRECISION NOT NULL,
+		high DOUBLE PRECISION NOT NULL,
+		low DOUBLE PRECISION NOT NULL,
+		close DOUBLE PRECISION NOT NULL,
+		samples INTEGER NOT NULL,
+		PRIMARY KEY (coin, interval, open_time)
+	)`,
+	`CREATE TABLE IF NOT EXISTS price_alerts (
+		id SERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		coin TEXT NOT NULL,
+		rule TEXT NOT NULL,
+		price DOUBLE PRECISION NOT NULL,
+		change DOUBLE PRECISION NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS price_alerts_coin_rule ON price_alerts (coin, rule, created_at DESC)`,
+	// tables that predate migrations, so a fresh database has everything later migrations alter
+	`CREATE TABLE IF NOT EXISTS exchange_rates (
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		coin TEXT NOT NULL,
+		value DOUBLE PRECISION NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS sentiments (
+		id SERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		coin TEXT NOT NULL,
+		sentiment INTEGER NOT NULL,
+		source TEXT NOT NULL
+	);
+	CREATE TABLE IF NOT EXISTS rss_posts (
+		id SERIAL PRIMARY KEY,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		url TEXT NOT NULL
+	)`,
+	// graded sentiment. Earlier rows keep their -1, 0 or 1 as the score
+	`ALTER TABLE sentiments
+		ADD COLUMN IF NOT EXISTS score DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION,
+		ADD COLUMN IF NOT EXISTS rationale TEXT;
+	UPDATE sentiments SET score = sentiment WHERE score IS NULL`,
+	`CREATE TABLE IF NOT EXISTS sentiment_index (
+		coin TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL,
+		value DOUBLE PRECISION NOT NULL,
+		headlines INTEGER NOT NULL,
+		weight DOUBLE PRECISION NOT NULL,
+		PRIMARY KEY (coin, created_at)
+	)`,
+	`ALTER TABLE sentiments
+		ADD COLUMN IF NOT EXISTS title TEXT,
+		ADD COLUMN IF NOT EXISTS publisher TEXT`,
+	`CREATE TABLE IF NOT EXISTS job_runs (
+		id BIGSERIAL PRIMARY KEY,
+		job TEXT NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		finished_at TIMESTAMPTZ,
+		status TEXT NOT NULL,
+		items INTEGER NOT NULL DEFAULT 0,
+		error TEXT
+	);
+	CREATE INDEX IF NOT EXISTS job_runs_job_started_at ON job_runs (job, started_at DESC)`,
+	`ALTER TABLE job_runs
+		ADD COLUMN IF NOT EXISTS trigger TEXT NOT NULL DEFAULT 'schedule',
+		ADD COLUMN IF NOT EXISTS params TEXT`,
+}
+
+// bring the database schema up to date, recording each applied migration in schema_migrations.
+// Replicas starting together take turns, the later ones finding nothing left to apply
+func migrate(ctx context.Context) error {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	key := advisoryLockKey("migrate")
+	_, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", key)
+	if err != nil {
+		return err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+
+	_, err = conn.Exec(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())")
+	if err != nil {
+		return err
+	}
+
+	var current int
+	err = conn.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		log.Println("Applying migration", version)
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, migrations[i])
+		if err == nil {
+			_, err = tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("migration %d: %w", version, err)
+		}
+		err = tx.Commit(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"ghost/writer/lexicon"
+)
+
+// sentiment of a headline as returned by the llm
+type HeadlineSentiment struct {
+	Score      float64 `json:"score"`      // -1.0 very negative to 1.0 very positive
+	Confidence float64 `json:"confidence"` // 0.0 to 1.0
+	Rationale  string  `json:"rationale"`
+}
+
+// scores closer to zero than this count as neutral when reduced to a direction
+var neutralScoreBand = 0.1
+
+func headlineSentimentPrompt(text string, coin string) string {
+	return "Rate how the following headline could affect the market value of " + coin + ". " +
+		`Reply with only a JSON object of the form {"score": number, "confidence": number, "rationale": string}, without markdown. ` +
+		"score is from -1.0 (very negative) to 1.0 (very positive), 0 for no effect. " +
+		"confidence is from 0.0 to 1.0. rationale is one short sentence. Headline: " + text
+}
+
+// parse the llm reply, rejecting anything that isn't a well formed rating
+func parseHeadlineSentiment(reply string) (HeadlineSentiment, error) {
+	var sentiment HeadlineSentiment
+
+	// models sometimes wrap the object in a code fence or add a sentence around it
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return sentiment, fmt.Errorf("no sentiment object in reply %q", reply)
+	}
+
+	err := json.Unmarshal([]byte(reply[start:end+1]), &sentiment)
+	if err != nil {
+		return sentiment, fmt.Errorf("parsing sentiment reply %q: %w", reply, err)
+	}
+
+	if math.IsNaN(sentiment.Score) || sentiment.Score < -1 || sentiment.Score > 1 {
+		return sentiment, fmt.Errorf("sentiment score %v out of range", sentiment.Score)
+	}
+	if math.IsNaN(sentiment.Confidence) || sentiment.Confidence < 0 || sentiment.Confidence > 1 {
+		return sentiment, fmt.Errorf("sentiment confidence %v out of range", sentiment.Confidence)
+	}
+	sentiment.Rationale = strings.TrimSpace(sentiment.Rationale)
+	if sentiment.Rationale == "" {
+		return sentiment, errors.New("sentiment reply has no rationale")
+	}
+
+	return sentiment, nil
+}
+
+// reduce a score to -1, 0 or 1
+func (s HeadlineSentiment) Direction() int {
+	if s.Score >= neutralScoreBand {
+		return 1
+	}
+	if s.Score <= -neutralScoreBand {
+		return -1
+	}
+	return 0
+}
+
+// most headline sentiment calls sent to the llm per day, beyond which the lexicon is used
+var sentimentLLMDailyBudget = 200
+
+// llm and lexicon scores further apart than this are logged for review
+var sentimentDisagreement = 1.0
+
+// counts llm calls against a daily budget
+type callBudget struct {
+	mu    sync.Mutex
+	day   string
+	calls int
+}
+
+var sentimentBudget = &callBudget{}
+
+// reserve a call, returning false once the day's budget is spent
+func (b *callBudget) allow(limit int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	today := time.Now().UTC().Format("2006-01-02")
+	if b.day != today {
+		b.day = today
+		b.calls = 0
+	}
+	if b.calls >= limit {
+		return false
+	}
+	b.calls++
+	return true
+}
+
+// score a headline with the local lexicon. Confidence grows with the number of matched words, but stays below typical llm confidence
+func lexiconSentiment(text string) HeadlineSentiment {
+	result := lexicon.Score(text)
+
+	sentiment := HeadlineSentiment{
+		Score:      result.Score,
+		Confidence: math.Min(0.2+0.1*float64(len(result.Matches)), 0.5),
+		Rationale:  "Lexicon match: none",
+	}
+	if len(result.Matches) > 0 {
+		sentiment.Rationale = "Lexicon match: " + strings.Join(result.Matches, ", ")
+	} else {
+		sentiment.Confidence = 0.1
+	}
+
+	return sentiment
+}
+
+// log headlines where the llm and the lexicon clearly disagree, so either can be reviewed
+func crossCheckSentiment(text string, coin string, llm HeadlineSentiment) {
+	local := lexicon.Score(text)
+	if len(local.Matches) == 0 {
+		return
+	}
+
+	opposite := llm.Direction()*lexiconSentiment(text).Direction() < 0
+	if opposite || math.Abs(llm.Score-local.Score) > sentimentDisagreement {
+		log.Printf("Sentiment disagreement for %s: llm %.2f (%s), lexicon %.2f (%s): %q",
+			coin, llm.Score, llm.Rationale, local.Score, strings.Join(local.Matches, ", "), text)
+	}
+}
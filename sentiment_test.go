@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestParseHeadlineSentiment(t *testing.T) {
+	got, err := parseHeadlineSentiment("```json\n{\"score\": -0.6, \"confidence\": 0.8, \"rationale\": \"A hack erodes trust.\"}\n```")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Score != -0.6 || got.Confidence != 0.8 || got.Rationale != "A hack erodes trust." || got.Direction() != -1 {
+		t.Errorf("unexpected sentiment %+v", got)
+	}
+
+	bad := []string{
+		"1",
+		"positive",
+		`{"score": 2, "confidence": 0.5, "rationale": "too high"}`,
+		`{"score": 0.2, "confidence": -1, "rationale": "bad confidence"}`,
+		`{"score": 0.2, "confidence": 0.5}`,
+		`{"score": "high", "confidence": 0.5, "rationale": "not a number"}`,
+	}
+	for _, reply := range bad {
+		if _, err := parseHeadlineSentiment(reply); err == nil {
+			t.Errorf("expected an error for %q", reply)
+		}
+	}
+
+	if d := (HeadlineSentiment{Score: 0.05}).Direction(); d != 0 {
+		t.Errorf("expected a neutral direction, got %d", d)
+	}
+}
+
+func TestLexiconSentiment(t *testing.T) {
+	got := lexiconSentiment("Exchange hacked, funds stolen")
+	if got.Direction() != -1 || got.Confidence > 0.5 || got.Rationale == "" {
+		t.Errorf("unexpected sentiment %+v", got)
+	}
+
+	if got := lexiconSentiment("Quarterly report published"); got.Score != 0 || got.Confidence != 0.1 {
+		t.Errorf("expected a low confidence neutral score, got %+v", got)
+	}
+}
+
+func TestCallBudget(t *testing.T) {
+	b := &callBudget{}
+	if !b.allow(2) || !b.allow(2) || b.allow(2) {
+		t.Error("expected exactly two calls to be allowed")
+	}
+
+	// a new day resets the count
+	b.day = "2000-01-01"
+	if !b.allow(2) {
+		t.Error("expected the budget to reset")
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// a headline's weight halves every sentimentHalfLife
+var sentimentHalfLife = 12 * time.Hour
+
+// headlines older than this no longer count towards the index
+var sentimentLookback = 7 * 24 * time.Hour
+
+// pulls the index towards neutral when there are only a few recent headlines, in units of fresh headlines
+var sentimentPriorWeight = 1.0
+
+// how much each publisher counts, by host. Anything not listed counts once
+var sourceWeights = map[string]float64{
+	"www.coindesk.com":       1.2,
+	"cointelegraph.com":      1.2,
+	"cryptoslate.com":        1.0,
+	"cryptobriefing.com":     1.0,
+	"cryptonews.com":         0.9,
+	"cryptopotato.com":       0.8,
+	"cryptocurrencynews.com": 0.8,
+}
+
+// headline sentiment of a coin at a point in time, from -1.0 to 1.0
+type SentimentIndex struct {
+	Coin      string    `json:"coin"`
+	CreatedAt time.Time `json:"created_at"`
+	Value     float64   `json:"value"`
+	Headlines int       `json:"headlines"`
+	Weight    float64   `json:"weight"` // total decayed weight of the headlines
+}
+
+func sourceWeight(source string) float64 {
+	u, err := url.Parse(source)
+	if err != nil {
+		return 1
+	}
+	if weight, ok := sourceWeights[u.Hostname()]; ok {
+		return weight
+	}
+	return 1
+}
+
+// combine scored headlines into an index as of the given time
+func sentimentIndex(coin string, sentiments []CoinSentiment, at time.Time) SentimentIndex {
+	index := SentimentIndex{Coin: coin, CreatedAt: at}
+
+	total := 0.0
+	for _, s := range sentiments {
+		age := at.Sub(s.createdAt)
+		if age < 0 || age > sentimentLookback {
+			continue
+		}
+
+		weight := math.Pow(0.5, age.Hours()/sentimentHalfLife.Hours()) * sourceWeight(s.source) * s.confidence
+		total += weight * s.score
+		index.Weight += weight
+		index.Headlines++
+	}
+
+	index.Value = total / (index.Weight + sentimentPriorWeight)
+	return index
+}
+
+// compute the current index of a coin from the sentiments table
+func computeSentimentIndex(ctx context.Context, coin string) (SentimentIndex, error) {
+	now := time.Now()
+
+	sentiments, err := getScoredSentiments(ctx, coin, now.Add(-sentimentLookback))
+	if err != nil {
+		return SentimentIndex{}, err
+	}
+
+	return sentimentIndex(coin, sentiments, now), nil
+}
+
+// get the scored headlines of a coin since the given time
+func getScoredSentiments(ctx context.Context, coin string, since time.Time) ([]CoinSentiment, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx,
+		"SELECT created_at, source, COALESCE(score, sentiment), COALESCE(confidence, 1) FROM sentiments WHERE coin = $1 AND created_at > $2",
+		coin, since)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sentiments := []CoinSentiment{}
+	for rows.Next() {
+		var s CoinSentiment
+		err = rows.Scan(&s.createdAt, &s.source, &s.score, &s.confidence)
+		if err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		sentiments = append(sentiments, s)
+	}
+
+	return sentiments, nil
+}
+
+// fill in the index of a coin at the close of every hour since the given time that has no stored point,
+// from the headlines stored at the time. Returns how many points were added
+func backfillSentimentIndex(ctx context.Context, coin string, since time.Time) (int, error) {
+	sentiments, err := getScoredSentiments(ctx, coin, since.Add(-sentimentLookback))
+	if err != nil {
+		return 0, err
+	}
+
+	added := 0
+	now := time.Now()
+	for at := since.Truncate(time.Hour).Add(time.Hour); at.Before(now); at = at.Add(time.Hour) {
+		index := sentimentIndex(coin, sentiments, at)
+		tag, err := dbExec(ctx,
+			`INSERT INTO sentiment_index (coin, created_at, value, headlines, weight) SELECT $1, $2, $3, $4, $5
+			WHERE NOT EXISTS (SELECT 1 FROM sentiment_index WHERE coin = $1 AND created_at > $2 - interval '1 hour' AND created_at <= $2)`,
+			index.Coin, index.CreatedAt, index.Value, index.Headlines, index.Weight)
+		if err != nil {
+			return added, err
+		}
+		added += int(tag.RowsAffected())
+	}
+
+	return added, nil
+}
+
+// compute and store the current index of every coin
+func recordSentimentIndexes(ctx context.Context, coins []string) {
+	for _, coin := range coins {
+		index, err := computeSentimentIndex(ctx, coin)
+		if err != nil {
+			continue
+		}
+
+		if dryRunFrom(ctx) != nil {
+			continue
+		}
+
+		_, err = dbExec(ctx, "INSERT INTO sentiment_index (coin, created_at, value, headlines, weight) VALUES ($1, $2, $3, $4, $5)",
+			index.Coin, index.CreatedAt, index.Value, index.Headlines, index.Weight)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// get the stored index of a coin from start to now, oldest first
+func getSentimentIndexRange(ctx context.Context, coin string, start time.Time) ([]SentimentIndex, error) {
+	indexes := []SentimentIndex{}
+
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	rows, err := db.Query(ctx, "SELECT coin, created_at, value, headlines, weight FROM sentiment_index WHERE coin = $1 AND created_at > $2 ORDER BY created_at ASC", coin, start)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		return indexes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var index SentimentIndex
+		err = rows.Scan(&index.Coin, &index.CreatedAt, &index.Value, &index.Headlines, &index.Weight)
+		if err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+// a plain text description of the index for llm prompts
+func (i SentimentIndex) Prompt() string {
+	if i.Headlines == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Headline sentiment index of %s: %.2f on a scale from -1 (very negative) to 1 (very positive), from %d headlines in the past week, weighted towards recent ones.", i.Coin, i.Value, i.Headlines)
+}
+
+// overall sentiment across coins, weighting each coin by how much it is in the news
+type CryptoMood struct {
+	Value float64          `json:"value"`
+	Label string           `json:"label"`
+	Coins []SentimentIndex `json:"coins"`
+}
+
+func cryptoMood(indexes []SentimentIndex) CryptoMood {
+	mood := CryptoMood{Coins: indexes}
+
+	total, weight := 0.0, 0.0
+	for _, index := range indexes {
+		total += index.Value * index.Weight
+		weight += index.Weight
+	}
+	if weight > 0 {
+		mood.Value = total / weight
+	}
+
+	switch {
+	case mood.Value <= -0.5:
+		mood.Label = "Fearful"
+	case mood.Value <= -0.15:
+		mood.Label = "Cautious"
+	case mood.Value < 0.15:
+		mood.Label = "Neutral"
+	case mood.Value < 0.5:
+		mood.Label = "Optimistic"
+	default:
+		mood.Label = "Euphoric"
+	}
+
+	return mood
+}
+
+func currentCryptoMood(ctx context.Context) CryptoMood {
+	indexes := []SentimentIndex{}
+	for _, coin := range watchlist {
+		index, err := computeSentimentIndex(ctx, coin)
+		if err != nil {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	return cryptoMood(indexes)
+}
+
+// returns html of the crypto mood for embedding in posts
+func moodWidget(mood CryptoMood) template.HTML {
+	html, err := templates.Render("mood", mood)
+	if err != nil {
+		log.Println("Error executing template:", err)
+		return ""
+	}
+
+	return template.HTML(html)
+}
+
+// serves the current crypto mood as json
+func moodHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentCryptoMood(r.Context()))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSentimentIndex(t *testing.T) {
+	now := time.Now()
+
+	// one fresh positive headline is pulled halfway to neutral by the prior
+	one := sentimentIndex("BTC", []CoinSentiment{
+		{createdAt: now, source: "https://example.com/a", score: 1, confidence: 1},
+	}, now)
+	if math.Abs(one.Value-0.5) > 1e-9 || one.Headlines != 1 {
+		t.Errorf("unexpected index %+v", one)
+	}
+
+	// an old negative headline counts less than a fresh positive one
+	mixed := sentimentIndex("BTC", []CoinSentiment{
+		{createdAt: now, source: "https://example.com/a", score: 1, confidence: 1},
+		{createdAt: now.Add(-sentimentHalfLife), source: "https://example.com/b", score: -1, confidence: 1},
+		{createdAt: now.Add(-30 * 24 * time.Hour), source: "https://example.com/c", score: -1, confidence: 1},
+	}, now)
+	if mixed.Value <= 0 || mixed.Headlines != 2 || math.Abs(mixed.Weight-1.5) > 1e-9 {
+		t.Errorf("unexpected index %+v", mixed)
+	}
+
+	if empty := sentimentIndex("BTC", nil, now); empty.Value != 0 || empty.Prompt() != "" {
+		t.Errorf("unexpected empty index %+v", empty)
+	}
+}
+
+func TestCryptoMood(t *testing.T) {
+	mood := cryptoMood([]SentimentIndex{
+		{Coin: "BTC", Value: 0.6, Weight: 3, Headlines: 3},
+		{Coin: "ETH", Value: -0.6, Weight: 1, Headlines: 1},
+	})
+	if math.Abs(mood.Value-0.3) > 1e-9 || mood.Label != "Optimistic" {
+		t.Errorf("unexpected mood %+v", mood)
+	}
+
+	if html := moodWidget(mood); html == "" {
+		t.Error("expected a mood widget")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var ErrShuttingDown = errors.New("interrupted by shutdown")
+
+// closed when the process starts shutting down. Jobs finish the post in hand and stop before the next one
+var draining = make(chan struct{})
+var drainOnce sync.Once
+
+// every run derives its context from this one, which is cancelled if runs are still going at the shutdown deadline
+var runContext, cancelRuns = context.WithCancel(context.Background())
+
+func startDraining() {
+	drainOnce.Do(func() { close(draining) })
+}
+
+func isDraining() bool {
+	select {
+	case <-draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// waits between items of a job, returning ErrShuttingDown instead if the process starts shutting down meanwhile
+func pause(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-draining:
+		return ErrShuttingDown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stops the scheduler and waits for running jobs up to the timeout, then cancels whatever is left
+func drainRuns(stopScheduler func() error, timeout time.Duration) {
+	startDraining()
+	deadline := time.AfterFunc(timeout, func() {
+		log.Println("Shutdown deadline reached, cancelling running jobs")
+		cancelRuns()
+	})
+	defer deadline.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		err := stopScheduler()
+		if err != nil {
+			log.Println("Error stopping scheduler:", err)
+		}
+		jobRuns.wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Running jobs finished")
+	case <-time.After(timeout + 5*time.Second):
+		// cancelled runs still have a moment to record how they ended
+		log.Println("Gave up waiting for running jobs")
+	}
+	cancelRuns()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gives the test a process that hasn't started shutting down, with its own run tracker
+func resetShutdown(t *testing.T) {
+	savedDraining, savedRuns := draining, jobRuns
+	savedContext, savedCancel := runContext, cancelRuns
+	t.Cleanup(func() {
+		draining, drainOnce, jobRuns = savedDraining, sync.Once{}, savedRuns
+		runContext, cancelRuns = savedContext, savedCancel
+	})
+
+	draining, drainOnce, jobRuns = make(chan struct{}), sync.Once{}, newRunTracker()
+	runContext, cancelRuns = context.WithCancel(context.Background())
+}
+
+func TestPause(t *testing.T) {
+	err := pause(context.Background(), time.Millisecond)
+	if err != nil {
+		t.Errorf("pause = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	err = pause(ctx, time.Hour)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("pause on a cancelled context = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("pause on a cancelled context waited")
+	}
+}
+
+func TestRunTrackerWait(t *testing.T) {
+	tracker := newRunTracker()
+	tracker.wg.Add(1)
+
+	waited := make(chan struct{})
+	go func() {
+		tracker.wait()
+		close(waited)
+	}()
+
+	select {
+	case <-waited:
+		t.Fatal("wait returned while a run was in progress")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	tracker.wg.Done()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatal("wait didn't return after the run finished")
+	}
+}
+
+func TestDrainRuns(t *testing.T) {
+	resetShutdown(t)
+	ended := fakeJobRunStore(t)
+
+	// one job stops between items, the other runs until it's cancelled
+	paused := make(chan error, 1)
+	_, err := jobRuns.runManually("rss", "", false, func(ctx context.Context) (int, error) {
+		err := pause(ctx, time.Hour)
+		paused <- err
+		return 0, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = jobRuns.runManually("markets", "", false, func(ctx context.Context) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := false
+	start := time.Now()
+	drainRuns(func() error {
+		stopped = true
+		return nil
+	}, 50*time.Millisecond)
+	elapsed := time.Since(start)
+
+	if !stopped {
+		t.Error("expected the scheduler to be stopped")
+	}
+	if err := <-paused; !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("pause = %v, want ErrShuttingDown", err)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("drained in %s, expected to wait for the deadline", elapsed)
+	}
+	if runContext.Err() == nil {
+		t.Error("expected runs to be cancelled")
+	}
+
+	failed := map[string]string{}
+	for _, run := range ended() {
+		failed[run.Job] = run.Error
+	}
+	if failed["rss"] != ErrShuttingDown.Error() || failed["markets"] != context.Canceled.Error() {
+		t.Errorf("unexpected runs %v", failed)
+	}
+
+	if _, err := jobRuns.runManually("rss", "", false, nil); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("run after shutdown = %v, want ErrShuttingDown", err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"ghost/writer/indicators"
+)
+
+// latest technical indicators of a coin, computed from daily candles. Values without enough history are NaN
+type TechnicalSummary struct {
+	Coin            string
+	Days            int
+	SMA20           float64
+	SMA50           float64
+	EMA12           float64
+	EMA26           float64
+	RSI14           float64
+	MACD            float64
+	MACDSignal      float64
+	MACDHistogram   float64
+	BollingerUpper  float64
+	BollingerMiddle float64
+	BollingerLower  float64
+	Volatility      float64 // annualized, as a fraction
+	MaxDrawdown     float64 // over the whole period, as a fraction
+}
+
+type IndicatorRow struct {
+	Name  string
+	Value string
+}
+
+// how much daily history the indicators are computed over
+var technicalsLookback = 90 * 24 * time.Hour
+
+func technicalSummary(ctx context.Context, coin string) (TechnicalSummary, error) {
+	candles, err := getCandles(ctx, coin, Interval1d, time.Now().Add(-technicalsLookback))
+	if err != nil {
+		return TechnicalSummary{}, err
+	}
+
+	closes := make([]float64, 0, len(candles))
+	for _, c := range candles {
+		closes = append(closes, c.Close)
+	}
+
+	return summarizeCloses(coin, closes), nil
+}
+
+func summarizeCloses(coin string, closes []float64) TechnicalSummary {
+	macd, signal, hist := indicators.MACD(closes, 12, 26, 9)
+	middle, upper, lower := indicators.Bollinger(closes, 20, 2)
+
+	return TechnicalSummary{
+		Coin:            coin,
+		Days:            len(closes),
+		SMA20:           indicators.Last(indicators.SMA(closes, 20)),
+		SMA50:           indicators.Last(indicators.SMA(closes, 50)),
+		EMA12:           indicators.Last(indicators.EMA(closes, 12)),
+		EMA26:           indicators.Last(indicators.EMA(closes, 26)),
+		RSI14:           indicators.Last(indicators.RSI(closes, 14)),
+		MACD:            indicators.Last(macd),
+		MACDSignal:      indicators.Last(signal),
+		MACDHistogram:   indicators.Last(hist),
+		BollingerUpper:  indicators.Last(upper),
+		BollingerMiddle: indicators.Last(middle),
+		BollingerLower:  indicators.Last(lower),
+		Volatility:      indicators.Volatility(closes, 365),
+		MaxDrawdown:     indicators.MaxDrawdown(closes),
+	}
+}
+
+// indicators with enough history to be shown, formatted for posts
+func (t TechnicalSummary) Rows() []IndicatorRow {
+	return t.rows(postFormatter)
+}
+
+func (t TechnicalSummary) rows(f *Formatter) []IndicatorRow {
+	rows := []IndicatorRow{}
+	add := func(name string, value float64, format func(float64) string) {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return
+		}
+		rows = append(rows, IndicatorRow{Name: name, Value: format(value)})
+	}
+
+	oneDecimal := func(v float64) string { return f.Number(v, 1) }
+	fourDigits := func(v float64) string { return f.Significant(v, 4) }
+
+	add("SMA (20d)", t.SMA20, f.Price)
+	add("SMA (50d)", t.SMA50, f.Price)
+	add("EMA (12d)", t.EMA12, f.Price)
+	add("EMA (26d)", t.EMA26, f.Price)
+	add("RSI (14d)", t.RSI14, oneDecimal)
+	add("MACD", t.MACD, fourDigits)
+	add("MACD signal", t.MACDSignal, fourDigits)
+	add("MACD histogram", t.MACDHistogram, fourDigits)
+	add("Bollinger upper", t.BollingerUpper, f.Price)
+	add("Bollinger middle", t.BollingerMiddle, f.Price)
+	add("Bollinger lower", t.BollingerLower, f.Price)
+	add("Volatility (annualized %)", t.Volatility*100, oneDecimal)
+	add("Max drawdown (%)", t.MaxDrawdown*100, oneDecimal)
+
+	return rows
+}
+
+// a plain text description of the indicators for llm prompts
+func (t TechnicalSummary) Prompt() string {
+	rows := t.rows(promptFormatter)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		parts = append(parts, row.Name+": "+row.Value)
+	}
+
+	return fmt.Sprintf("Technical indicators of %s from %d days of daily closes: %s.", t.Coin, t.Days, strings.Join(parts, ", "))
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestSummarizeCloses(t *testing.T) {
+	closes := make([]float64, 60)
+	for i := range closes {
+		closes[i] = 100 + float64(i)
+	}
+
+	rows := summarizeCloses("BTC", closes).Rows()
+	if len(rows) != 13 {
+		t.Errorf("expected every indicator with 60 days of history, got %v", rows)
+	}
+
+	// too little history leaves out the long averages instead of printing NaN
+	short := summarizeCloses("BTC", closes[:10])
+	for _, row := range short.Rows() {
+		if row.Name == "SMA (50d)" || row.Value == "NaN" {
+			t.Errorf("unexpected row %v", row)
+		}
+	}
+	if short.Prompt() == "" {
+		t.Error("expected a prompt from 10 days of history")
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// post layouts loaded from a directory, so they can be changed without a build.
+// Every *.html file is a template named after the file, and may use the templates defined in the others
+type TemplateSet struct {
+	dir   string
+	mu    sync.RWMutex
+	tmpl  *template.Template
+	files map[string]time.Time // modification time of each file the templates were parsed from
+}
+
+var templates *TemplateSet
+
+func loadTemplates(dir string) (*TemplateSet, error) {
+	t := &TemplateSet{dir: dir}
+	err := t.reload()
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parse every template in the directory, keeping the previous set if any of them fail
+func (t *TemplateSet) reload() error {
+	files, err := t.scan()
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no templates in %s", t.dir)
+	}
+
+	tmpl, err := template.New("").Funcs(postFormatter.FuncMap()).ParseGlob(filepath.Join(t.dir, "*.html"))
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	t.tmpl = tmpl
+	t.files = files
+	t.mu.Unlock()
+
+	return nil
+}
+
+// modification time of each template in the directory
+func (t *TemplateSet) scan() (map[string]time.Time, error) {
+	names, err := filepath.Glob(filepath.Join(t.dir, "*.html"))
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string]time.Time{}
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if errors.Is(err, os.ErrNotExist) {
+			// removed since the glob
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		files[name] = info.ModTime()
+	}
+
+	return files, nil
+}
+
+// reload the templates whenever a file in the directory is added, changed or removed, until ctx is done.
+// A reload that fails is retried after the next change, the previous templates stay in use until then
+func (t *TemplateSet) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	t.mu.RLock()
+	seen := t.files
+	t.mu.RUnlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, err := t.scan()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if maps.Equal(files, seen) {
+			continue
+		}
+		seen = files
+
+		log.Println("Reloading templates")
+		err = t.reload()
+		if err != nil {
+			log.Println("Error reloading templates:", err)
+		}
+	}
+}
+
+// execute the named template, e.g. "forecast.html" or a partial such as "chatter"
+func (t *TemplateSet) Render(name string, data interface{}) (string, error) {
+	t.mu.RLock()
+	tmpl := t.tmpl
+	t.mu.RUnlock()
+
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, name, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTemplateReload(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "post.html")
+	if err := os.WriteFile(file, []byte("one {{.}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := loadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := set.Render("post.html", 1); got != "one 1" {
+		t.Errorf("unexpected render %q", got)
+	}
+
+	// a broken template keeps the previous one
+	if err := os.WriteFile(file, []byte("two {{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.reload(); err == nil {
+		t.Error("expected a parse error")
+	}
+	if got, _ := set.Render("post.html", 1); got != "one 1" {
+		t.Errorf("unexpected render %q", got)
+	}
+
+	if err := os.WriteFile(file, []byte("three {{.}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := set.Render("post.html", 1); got != "three 1" {
+		t.Errorf("unexpected render %q", got)
+	}
+}
+
+func TestTemplateWatch(t *testing.T) {
+	dir := t.TempDir()
+	for name, text := range map[string]string{"post.html": "post", "old.html": "old"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set, err := loadTemplates(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		set.watch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	// a removed template is noticed even though no remaining file changed
+	if err := os.Remove(filepath.Join(dir, "old.html")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := set.Render("old.html", nil); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("removed template still renders")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, _ := set.Render("post.html", nil); got != "post" {
+		t.Errorf("unexpected render %q", got)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("watch didn't stop with its context")
+	}
+}
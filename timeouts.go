@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// how long a single call to each dependency may take before it's abandoned
+type Timeouts struct {
+	Database   time.Duration `yaml:"database"`
+	Ghost      time.Duration `yaml:"ghost"`
+	CoinMarket time.Duration `yaml:"coinmarket"`
+	Unsplash   time.Duration `yaml:"unsplash"`
+	LLM        time.Duration `yaml:"llm"`
+	Web        time.Duration `yaml:"web"` // rss feeds and the articles they link to
+}
+
+func defaultTimeouts() Timeouts {
+	return Timeouts{
+		Database:   10 * time.Second,
+		Ghost:      30 * time.Second,
+		CoinMarket: 15 * time.Second,
+		Unsplash:   15 * time.Second,
+		LLM:        2 * time.Minute,
+		Web:        30 * time.Second,
+	}
+}
+
+// a context for one database query, which has to be cancelled once its rows have been read
+func dbContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Timeouts.Database)
+}
+
+// runs a statement, giving up after the database timeout
+func dbExec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, cancel := dbContext(ctx)
+	defer cancel()
+	return db.Exec(ctx, sql, args...)
+}
+
+// a context for one llm prompt
+func llmContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, config.Timeouts.LLM)
+}
+
+// a client whose requests, including reading the response, give up after the timeout
+func httpClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout}
+}
+
+// a collector for scraping web pages, whose requests are cancelled along with ctx
+func newCollector(ctx context.Context) *colly.Collector {
+	c := colly.NewCollector()
+	c.SetRequestTimeout(config.Timeouts.Web)
+	c.WithTransport(contextTransport{ctx: ctx, next: http.DefaultTransport})
+	return c
+}
+
+// sends requests with its context, for clients such as colly that don't take one
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	_, err := httpClient(20 * time.Millisecond).Get(server.URL)
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("request took %s, longer than its timeout", time.Since(start))
+	}
+}
+
+func TestContextTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &http.Client{Transport: contextTransport{ctx: ctx, next: http.DefaultTransport}}
+
+	res, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	cancel()
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("expected requests to fail once the context is cancelled")
+	}
+}